@@ -2,101 +2,355 @@ package ai
 
 import (
 	"chatgpt/config"
+	"chatgpt/logging"
+	"chatgpt/metrics"
 	"chatgpt/models"
+	"chatgpt/tracing"
 	"context"
 	"errors"
 	"fmt"
+	"github.com/google/uuid"
 	"github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
 	"time"
 )
 
+const (
+	runPollInterval  = 2 * time.Second
+	runCancelTimeout = 10 * time.Second
+	// forkBatch is the most messages a thread can be created with, the rest are added one by one.
+	forkBatch = 32
+)
+
+// RunOptions tune a single assistant run.
+type RunOptions struct {
+	// Persona of the conversation, the default persona if empty.
+	Persona string
+	// UserId enables the user memory as context of the run.
+	UserId uuid.UUID
+	// Instructions are appended to the persona instructions for this run only.
+	Instructions string
+	// Locale of the user, the assistant replies in its language.
+	Locale string
+	// Conversation the thread belongs to, the thread itself if empty. A forked thread belongs to the conversation it
+	// was forked from, the memory of that one is used.
+	Conversation string
+}
+
+// conversation returns the conversation of the run on the thread.
+func (o RunOptions) conversation(threadId string) string {
+	if o.Conversation != "" {
+		return o.Conversation
+	}
+	return threadId
+}
+
 type AI struct {
 	client    *openai.Client
 	assistant *openai.Assistant
-	model     *string
+
+	mu             sync.RWMutex
+	assistants     map[string]*openai.Assistant
+	personas       map[string]models.Persona
+	configPersonas []models.Persona
+	defaultPersona string
+	tools          map[string]Tool
+
+	memory *Memory
+}
+
+var tracer = otel.Tracer("chatgpt/ai")
+
+func threadAttribute(threadId string) attribute.KeyValue {
+	return attribute.String("openai.thread.id", threadId)
+}
+
+// idSegment matches OpenAI object IDs in paths like /v1/threads/thread_abc/runs/run_def.
+var idSegment = regexp.MustCompile(`/[a-z]+_[A-Za-z0-9]+`)
+
+// requestIdTransport forwards the request ID of the context, so OpenAI calls can be matched with API requests.
+type requestIdTransport struct {
+	base http.RoundTripper
+}
+
+func (t requestIdTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if id := logging.RequestId(req.Context()); id != "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("X-Client-Request-Id", id)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	endpoint := idSegment.ReplaceAllString(req.URL.Path, "/{id}")
+	if err != nil {
+		metrics.OpenAICalls.WithLabelValues(req.Method, endpoint, "error").Inc()
+		return resp, err
+	}
+
+	metrics.OpenAICalls.WithLabelValues(req.Method, endpoint, strconv.Itoa(resp.StatusCode)).Inc()
+	slog.DebugContext(req.Context(), "openai call",
+		"method", req.Method, "path", req.URL.Path, "status", resp.StatusCode, "openaiRequestId", resp.Header.Get("X-Request-Id"))
+	return resp, err
 }
 
 func NewAI(config *config.Config) *AI {
-	client := openai.NewClient(config.OpenAiAuthToken)
+	clientConfig := openai.DefaultConfig(config.OpenAiAuthToken)
+	clientConfig.HTTPClient = &http.Client{Transport: otelhttp.NewTransport(requestIdTransport{http.DefaultTransport},
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return "openai " + r.Method + " " + idSegment.ReplaceAllString(r.URL.Path, "/{id}")
+		}))}
+	client := openai.NewClientWithConfig(clientConfig)
 
 	assistant, err := client.RetrieveAssistant(context.Background(), config.OpenAiAssistantId)
 	if err != nil {
-		fmt.Printf("Assistant error: %v\n", err)
+		slog.Error("retrieve assistant", "assistant", config.OpenAiAssistantId, "error", err)
 		panic(err)
 	}
 
-	// TODO what model or it comes from the assistant
-	model := openai.GPT3Dot5Turbo1106
+	a := &AI{
+		client:         client,
+		assistant:      &assistant,
+		assistants:     map[string]*openai.Assistant{assistant.ID: &assistant},
+		configPersonas: config.Personas,
+		defaultPersona: config.DefaultPersona,
+		tools:          make(map[string]Tool),
+	}
+	a.SetPersonas(config.Personas)
+
+	return a
+}
+
+// Ping checks that OpenAI is reachable and the default assistant exists.
+func (a *AI) Ping(ctx context.Context) error {
+	_, err := a.client.RetrieveAssistant(ctx, a.assistant.ID)
+	return err
+}
+
+func (a *AI) NewThread(ctx context.Context) (thread openai.Thread, err error) {
+	ctx, span := tracer.Start(ctx, "ai.NewThread")
+	defer func() { tracing.End(span, err) }()
 
-	return &AI{
-		client:    client,
-		assistant: &assistant,
-		model:     &model,
+	thread, err = a.client.CreateThread(ctx, openai.ThreadRequest{})
+	if err != nil {
+		return openai.Thread{}, err
 	}
+
+	return thread, nil
 }
 
-func (a *AI) NewThread(ctx context.Context) (openai.Thread, error) {
-	thread, err := a.client.CreateThread(ctx, openai.ThreadRequest{})
+// ForkThread starts a thread with a copy of the messages, so a run sees only them, e.g. a single branch of
+// a conversation. The copies get new IDs.
+func (a *AI) ForkThread(ctx context.Context, messages []models.Message) (thread openai.Thread, err error) {
+	ctx, span := tracer.Start(ctx, "ai.ForkThread", trace.WithAttributes(attribute.Int("openai.thread.messages", len(messages))))
+	defer func() { tracing.End(span, err) }()
+
+	copies := make([]openai.ThreadMessage, 0, len(messages))
+	for _, m := range messages {
+		if m.Text == "" {
+			continue
+		}
+		role := openai.ThreadMessageRoleUser
+		if m.Role == openai.ChatMessageRoleAssistant {
+			role = openai.ThreadMessageRoleAssistant
+		}
+		copies = append(copies, openai.ThreadMessage{Role: role, Content: m.Text})
+	}
+
+	first := copies[:min(len(copies), forkBatch)]
+	thread, err = a.client.CreateThread(ctx, openai.ThreadRequest{Messages: first})
 	if err != nil {
 		return openai.Thread{}, err
 	}
 
+	for _, m := range copies[len(first):] {
+		_, err = a.client.CreateMessage(ctx, thread.ID, openai.MessageRequest{Role: string(m.Role), Content: m.Content})
+		if err != nil {
+			a.deleteThreadQuietly(ctx, thread.ID)
+			return openai.Thread{}, err
+		}
+	}
+
 	return thread, nil
 }
 
-func (a *AI) NewMessage(ctx context.Context, threadId string, text string) (string, error) {
-	_, err := a.client.CreateMessage(ctx, threadId, openai.MessageRequest{
+// deleteThreadQuietly removes a thread that is no longer used, a failure only leaves it behind.
+func (a *AI) deleteThreadQuietly(ctx context.Context, threadId string) {
+	_, err := a.client.DeleteThread(context.WithoutCancel(ctx), threadId)
+	if err != nil {
+		slog.WarnContext(ctx, "delete thread", "thread", threadId, "error", err)
+	}
+}
+
+func (a *AI) DeleteThread(ctx context.Context, threadId string) (err error) {
+	ctx, span := tracer.Start(ctx, "ai.DeleteThread", trace.WithAttributes(threadAttribute(threadId)))
+	defer func() { tracing.End(span, err) }()
+
+	_, err = a.client.DeleteThread(ctx, threadId)
+	return err
+}
+
+func (a *AI) NewMessage(ctx context.Context, threadId string, text string, opts RunOptions) (_ string, err error) {
+	ctx, span := tracer.Start(ctx, "ai.NewMessage", trace.WithAttributes(threadAttribute(threadId)))
+	defer func() { tracing.End(span, err) }()
+
+	_, err = a.AddMessage(ctx, threadId, text)
+	if err != nil {
+		return "", err
+	}
+
+	reply, err := a.Run(ctx, threadId, opts)
+	if err != nil {
+		return "", err
+	}
+
+	return reply.Text, nil
+}
+
+// AddMessage appends the user message to the thread without running the assistant.
+func (a *AI) AddMessage(ctx context.Context, threadId string, text string) (_ models.Message, err error) {
+	ctx, span := tracer.Start(ctx, "ai.AddMessage", trace.WithAttributes(threadAttribute(threadId)))
+	defer func() { tracing.End(span, err) }()
+
+	msg, err := a.client.CreateMessage(ctx, threadId, openai.MessageRequest{
 		Role:    openai.ChatMessageRoleUser,
 		Content: text,
 	})
 	if err != nil {
-		return "", err
+		return models.Message{}, err
 	}
 
-	run, err := a.client.CreateRun(ctx, threadId, openai.RunRequest{
-		AssistantID: a.assistant.ID,
-	})
+	return toMessage(msg), nil
+}
+
+// Run runs the assistant on the thread and returns its reply.
+func (a *AI) Run(ctx context.Context, threadId string, opts RunOptions) (_ models.Message, err error) {
+	ctx, span := tracer.Start(ctx, "ai.Run", trace.WithAttributes(threadAttribute(threadId)))
+	defer func() { tracing.End(span, err) }()
+
+	request, err := a.runRequest(ctx, threadId, opts)
 	if err != nil {
-		return "", err
+		return models.Message{}, err
 	}
 
+	run, err := a.client.CreateRun(ctx, threadId, request)
+	if err != nil {
+		return models.Message{}, err
+	}
+
+	// The run is observed as error if polling fails before it reaches a final status.
+	runId := run.ID
+	started := time.Now()
+	status := "error"
+	polls := 0
+	defer func() {
+		if status == "error" && ctx.Err() != nil {
+			a.cancelRun(ctx, threadId, runId)
+		}
+		metrics.ObserveRun(status, started)
+		span.SetAttributes(
+			attribute.String("openai.run.id", runId),
+			attribute.String("openai.run.status", status),
+			attribute.Int("openai.run.polls", polls))
+	}()
+
 	for {
-		run, err = a.client.RetrieveRun(ctx, threadId, run.ID)
+		polls++
+		run, err = a.client.RetrieveRun(ctx, threadId, runId)
 		if err != nil {
-			return "", err
+			return models.Message{}, err
+		}
+		err = sleep(ctx, runPollInterval)
+		if err != nil {
+			return models.Message{}, err
 		}
-		time.Sleep(time.Second * 2)
 		switch run.Status {
 		case "in_progress":
-			time.Sleep(time.Second * 2)
+			err = sleep(ctx, runPollInterval)
+			if err != nil {
+				return models.Message{}, err
+			}
 		case "completed":
-			return a.GetLastMessage(ctx, threadId)
+			status = string(run.Status)
+			metrics.ObserveUsage(run.Model, run.Usage)
+			msg, err := a.lastMessage(ctx, threadId)
+			msg.Model = run.Model
+			return msg, err
 		case "requires_action":
-			return "required action", nil
+			run, err = a.submitToolOutputs(ctx, threadId, run, opts)
+			if err != nil {
+				return models.Message{}, err
+			}
 		case "expired":
-			return "", errors.New("run expired")
+			status = string(run.Status)
+			return models.Message{}, errors.New("run expired")
+		case "incomplete":
+			status = string(run.Status)
+			return models.Message{}, errors.New("run incomplete")
 		case "cancelling":
-			return "", errors.New("run cancelling")
+			status = string(run.Status)
+			return models.Message{}, errors.New("run cancelling")
 		case "cancelled":
-			return "", errors.New("run cancelled")
+			status = string(run.Status)
+			return models.Message{}, errors.New("run cancelled")
 		case "failed":
-			return "", fmt.Errorf("run failed: %s, code: %s", run.LastError.Message, run.LastError.Code)
+			status = string(run.Status)
+			return models.Message{}, fmt.Errorf("run failed: %s, code: %s", run.LastError.Message, run.LastError.Code)
 
 		}
 	}
 }
 
+// cancelRun stops a run whose caller is gone, e.g. on shutdown, so it does not keep working on the thread.
+func (a *AI) cancelRun(ctx context.Context, threadId string, runId string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), runCancelTimeout)
+	defer cancel()
+
+	_, err := a.client.CancelRun(ctx, threadId, runId)
+	if err != nil {
+		slog.WarnContext(ctx, "cancel run", "run", runId, "error", err)
+	}
+}
+
+// sleep waits for d unless ctx is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
 func (a *AI) GetLastMessage(ctx context.Context, threadId string) (string, error) {
-	msg, err := a.client.ListMessage(ctx, threadId, nil, nil, nil, nil)
+	msg, err := a.lastMessage(ctx, threadId)
 	if err != nil {
 		return "", err
 	}
 
-	if msg.Messages[0].Content[0].Text != nil {
-		return msg.Messages[0].Content[0].Text.Value, nil
+	return msg.Text, nil
+}
+
+func (a *AI) lastMessage(ctx context.Context, threadId string) (models.Message, error) {
+	msg, err := a.client.ListMessage(ctx, threadId, nil, nil, nil, nil)
+	if err != nil {
+		return models.Message{}, err
 	}
 
-	return "", errors.New("no response")
+	if len(msg.Messages) > 0 && len(msg.Messages[0].Content) > 0 && msg.Messages[0].Content[0].Text != nil {
+		return toMessage(msg.Messages[0]), nil
+	}
+
+	return models.Message{}, errors.New("no response")
 }
 
 func (a *AI) GetMessages(ctx context.Context, threadId string) ([]models.Message, error) {
@@ -109,7 +363,7 @@ func (a *AI) GetMessages(ctx context.Context, threadId string) ([]models.Message
 		var messages []models.Message
 
 		for _, m := range msg.Messages {
-			messages = append(messages, models.Message{Role: m.Role, Text: m.Content[0].Text.Value})
+			messages = append(messages, toMessage(m))
 		}
 
 		return messages, nil
@@ -120,3 +374,43 @@ func (a *AI) GetMessages(ctx context.Context, threadId string) ([]models.Message
 	messages := make([]models.Message, 0)
 	return messages, nil
 }
+
+// GetAllMessages pages through the whole thread and returns it in chronological order.
+func (a *AI) GetAllMessages(ctx context.Context, threadId string) ([]models.Message, error) {
+	limit := 100
+	order := "asc"
+	var after *string
+
+	messages := make([]models.Message, 0)
+	for {
+		msg, err := a.client.ListMessage(ctx, threadId, &limit, &order, after, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, m := range msg.Messages {
+			messages = append(messages, toMessage(m))
+		}
+
+		if len(msg.Messages) < limit {
+			return messages, nil
+		}
+		after = &msg.Messages[len(msg.Messages)-1].ID
+	}
+}
+
+func toMessage(m openai.Message) models.Message {
+	message := models.Message{
+		Id:           m.ID,
+		Conversation: m.ThreadID,
+		Role:         m.Role,
+		CreatedAt:    int64(m.CreatedAt),
+	}
+	if m.AssistantID != nil {
+		message.Assistant = *m.AssistantID
+	}
+	if len(m.Content) > 0 && m.Content[0].Text != nil {
+		message.Text = m.Content[0].Text.Value
+	}
+	return message
+}
@@ -0,0 +1,285 @@
+package ai
+
+import (
+	"chatgpt/metrics"
+	"chatgpt/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sashabaranov/go-openai"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	memoryTimeout   = 2 * time.Minute
+	memoryFactLimit = 50
+
+	memoryPrompt = `You maintain the memory of a therapy companion bot.
+You get the current summary of a conversation, the facts already known about the user and new messages.
+Reply with a JSON object:
+{"summary": "...", "facts": [{"kind": "...", "text": "..."}]}
+"summary" is the updated compact summary of the whole conversation, at most 150 words, in the language of the conversation.
+"facts" are only new durable facts about the user that are not known yet, kind is one of: %s.
+Do not include facts about the bot, passing small talk or anything the user asked to forget.`
+)
+
+// Memory summarizes conversations and keeps durable facts about the user,
+// both are given to the assistant as context of every run.
+type Memory struct {
+	ai    *AI
+	db    models.DbClient
+	every int
+	model string
+
+	mu      sync.Mutex
+	running map[string]bool
+	closed  bool
+
+	// updates tracks the background updates, cancel stops them if they outlive the shutdown.
+	updates sync.WaitGroup
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// UseMemory enables the memory, every is the count of new messages between summaries.
+func (a *AI) UseMemory(db models.DbClient, every int, model string) {
+	if every <= 0 {
+		return
+	}
+	if model == "" {
+		model = openai.GPT3Dot5Turbo1106
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.memory = &Memory{
+		ai:      a,
+		db:      db,
+		every:   every,
+		model:   model,
+		running: make(map[string]bool),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Close stops new memory updates and waits for the running ones until ctx is done, then cancels them.
+func (a *AI) Close(ctx context.Context) error {
+	m := a.memory
+	if m == nil {
+		return nil
+	}
+
+	m.mu.Lock()
+	m.closed = true
+	m.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.updates.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		m.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// Remember updates the memory of the conversation in background once enough messages are collected.
+func (a *AI) Remember(userId uuid.UUID, conversation string) {
+	m := a.memory
+	if m == nil || userId == uuid.Nil {
+		return
+	}
+
+	m.mu.Lock()
+	if m.closed || m.running[conversation] {
+		m.mu.Unlock()
+		return
+	}
+	m.running[conversation] = true
+	m.updates.Add(1)
+	m.mu.Unlock()
+
+	go func() {
+		defer m.updates.Done()
+		defer func() {
+			m.mu.Lock()
+			delete(m.running, conversation)
+			m.mu.Unlock()
+		}()
+
+		ctx, cancel := context.WithTimeout(m.ctx, memoryTimeout)
+		defer cancel()
+
+		err := m.Update(ctx, userId, conversation)
+		if err != nil {
+			slog.ErrorContext(ctx, "memory update", "conversation", conversation, "error", err)
+		}
+	}()
+}
+
+type memoryUpdate struct {
+	Summary string          `json:"summary"`
+	Facts   []models.Memory `json:"facts"`
+}
+
+// Update summarizes messages added since the last summary and stores new facts.
+func (m *Memory) Update(ctx context.Context, userId uuid.UUID, conversation string) error {
+	var filter models.FilterParams
+	filter.Filter = fmt.Sprintf(`conversation = '%v'`, conversation)
+
+	var summary models.ConversationSummary
+	err := m.db.Get(ctx, filter, &summary)
+	if models.AllowErrNotFound(err) != nil {
+		return err
+	}
+
+	// the stored messages of the conversation are counted, the thread is not paged on every turn
+	stored := models.FilterParams{
+		Filter: fmt.Sprintf(`conversation = '%v' and user_id = '%v'`, conversation, userId.String()),
+		Select: "count(*)",
+	}
+
+	var count int
+	err = m.db.Select(ctx, "messages", stored, &count)
+	if models.AllowErrNotFound(err) != nil {
+		return err
+	}
+	if count-summary.MessageCount < m.every {
+		return nil
+	}
+
+	stored.Select = "*"
+	stored.Orderings = "created_at, id"
+	stored.Offset = summary.MessageCount
+
+	var messages []models.Message
+	err = m.db.Select(ctx, "messages", stored, &messages)
+	if models.AllowErrNotFound(err) != nil {
+		return err
+	}
+
+	facts, err := m.facts(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	var input strings.Builder
+	fmt.Fprintf(&input, "Current summary:\n%s\n\nKnown facts:\n", summary.Summary)
+	for _, f := range facts {
+		fmt.Fprintf(&input, "- %s: %s\n", f.Kind, f.Text)
+	}
+	input.WriteString("\nNew messages:\n")
+	for _, msg := range messages {
+		fmt.Fprintf(&input, "%s: %s\n", msg.Role, msg.Text)
+	}
+
+	resp, err := m.ai.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model: m.model,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: fmt.Sprintf(memoryPrompt, strings.Join(models.MemoryKinds, ", "))},
+			{Role: openai.ChatMessageRoleUser, Content: input.String()},
+		},
+		ResponseFormat: &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject},
+	})
+	if err != nil {
+		return err
+	}
+	metrics.ObserveUsage(resp.Model, resp.Usage)
+	if len(resp.Choices) == 0 {
+		return errors.New("no summary")
+	}
+
+	var update memoryUpdate
+	err = json.Unmarshal([]byte(resp.Choices[0].Message.Content), &update)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range update.Facts {
+		f.Text = strings.TrimSpace(f.Text)
+		if f.Text == "" || slices.ContainsFunc(facts, func(k models.Memory) bool { return strings.EqualFold(k.Text, f.Text) }) {
+			continue
+		}
+		if !slices.Contains(models.MemoryKinds, f.Kind) {
+			f.Kind = models.MemoryKindFact
+		}
+
+		err = m.db.Create(ctx, &models.Memory{
+			UserId:       userId,
+			Kind:         f.Kind,
+			Text:         f.Text,
+			Conversation: conversation,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return m.db.Upsert(ctx, filter, &models.ConversationSummary{
+		Conversation: conversation,
+		UserId:       userId,
+		Summary:      update.Summary,
+		MessageCount: summary.MessageCount + len(messages),
+	})
+}
+
+func (m *Memory) facts(ctx context.Context, userId uuid.UUID) ([]models.Memory, error) {
+	var filter models.FilterParams
+	filter.Filter = fmt.Sprintf(`user_id = '%v'`, userId.String())
+	filter.Orderings = "created_at desc"
+	filter.Limit = memoryFactLimit
+
+	var facts []models.Memory
+	err := m.db.Get(ctx, filter, &facts)
+	return facts, models.AllowErrNotFound(err)
+}
+
+// Context describes what is known about the user: facts, the summary of the conversation
+// and of the latest previous one, so a new or recreated thread keeps the user in mind.
+func (m *Memory) Context(ctx context.Context, userId uuid.UUID, conversation string) (string, error) {
+	facts, err := m.facts(ctx, userId)
+	if err != nil {
+		return "", err
+	}
+
+	var filter models.FilterParams
+	filter.Filter = fmt.Sprintf(`user_id = '%v'`, userId.String())
+	filter.Orderings = "updated_at desc"
+	filter.Limit = 2
+
+	var summaries []models.ConversationSummary
+	err = m.db.Get(ctx, filter, &summaries)
+	if models.AllowErrNotFound(err) != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	if len(facts) > 0 {
+		b.WriteString("What you remember about the user:\n")
+		for _, f := range facts {
+			fmt.Fprintf(&b, "- %s: %s\n", f.Kind, f.Text)
+		}
+	}
+
+	for _, s := range summaries {
+		if s.Conversation == conversation {
+			fmt.Fprintf(&b, "\nSummary of this conversation so far:\n%s\n", s.Summary)
+		} else {
+			fmt.Fprintf(&b, "\nSummary of the previous conversation:\n%s\n", s.Summary)
+		}
+	}
+
+	return strings.TrimSpace(b.String()), nil
+}
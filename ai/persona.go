@@ -0,0 +1,222 @@
+package ai
+
+import (
+	"chatgpt/i18n"
+	"chatgpt/models"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sashabaranov/go-openai"
+	"slices"
+	"strings"
+)
+
+const DefaultPersona = "default"
+
+var ErrUnknownPersona = errors.New("unknown persona")
+
+// SetPersonas replaces the persona catalog.
+// The default persona is added from the configured assistant unless it is defined explicitly.
+func (a *AI) SetPersonas(personas []models.Persona) {
+	catalog := make(map[string]models.Persona, len(personas)+1)
+	catalog[DefaultPersona] = models.Persona{
+		Id:          DefaultPersona,
+		Name:        stringValue(a.assistant.Name),
+		Description: stringValue(a.assistant.Description),
+		AssistantId: a.assistant.ID,
+	}
+
+	for _, p := range personas {
+		if p.Id == "" {
+			continue
+		}
+		catalog[p.Id] = p
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.personas = catalog
+}
+
+// Configure replaces the configured personas and the default one, LoadPersonas applies them.
+func (a *AI) Configure(personas []models.Persona, defaultPersona string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.configPersonas = personas
+	a.defaultPersona = defaultPersona
+}
+
+// LoadPersonas merges personas stored in the db over the configured ones.
+func (a *AI) LoadPersonas(ctx context.Context, db models.DbClient) error {
+	var stored []models.Persona
+	err := db.Get(ctx, models.FilterParams{}, &stored)
+	if models.AllowErrNotFound(err) != nil {
+		return err
+	}
+
+	a.mu.RLock()
+	personas := slices.Clone(a.configPersonas)
+	a.mu.RUnlock()
+
+	for _, s := range stored {
+		i := slices.IndexFunc(personas, func(p models.Persona) bool { return p.Id == s.Id })
+		if i >= 0 {
+			personas[i] = s
+		} else {
+			personas = append(personas, s)
+		}
+	}
+
+	a.SetPersonas(personas)
+	return nil
+}
+
+// Personas returns enabled personas sorted by id.
+func (a *AI) Personas() []models.Persona {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	personas := make([]models.Persona, 0, len(a.personas))
+	for _, p := range a.personas {
+		if !p.Disabled {
+			personas = append(personas, p)
+		}
+	}
+	slices.SortFunc(personas, func(x, y models.Persona) int { return strings.Compare(x.Id, y.Id) })
+
+	return personas
+}
+
+// Persona returns the enabled persona, an empty id means the default one.
+func (a *AI) Persona(id string) (models.Persona, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if id == "" {
+		id = a.defaultPersona
+	}
+	if id == "" {
+		id = DefaultPersona
+	}
+
+	p, ok := a.personas[id]
+	if !ok || p.Disabled {
+		return models.Persona{}, ErrUnknownPersona
+	}
+
+	return p, nil
+}
+
+// runRequest builds the run for the persona of the conversation.
+func (a *AI) runRequest(ctx context.Context, threadId string, opts RunOptions) (openai.RunRequest, error) {
+	persona, err := a.Persona(opts.Persona)
+	if errors.Is(err, ErrUnknownPersona) {
+		// persona was removed after the conversation started.
+		persona, err = a.Persona("")
+	}
+	if err != nil {
+		return openai.RunRequest{}, err
+	}
+
+	assistant, err := a.retrieveAssistant(ctx, persona.AssistantId)
+	if err != nil {
+		return openai.RunRequest{}, err
+	}
+
+	request := openai.RunRequest{AssistantID: assistant.ID}
+
+	request.Model = persona.Model
+	request.Temperature = persona.Temperature
+
+	extra := opts.Instructions
+	if a.memory != nil && opts.UserId != uuid.Nil {
+		memory, err := a.memory.Context(ctx, opts.UserId, opts.conversation(threadId))
+		if err != nil {
+			return openai.RunRequest{}, err
+		}
+		extra = strings.TrimSpace(memory + "\n\n" + extra)
+	}
+	if language, ok := i18n.Languages[opts.Locale]; ok {
+		extra = strings.TrimSpace(extra + "\n\n" +
+			fmt.Sprintf("The user interface language is %s. Reply in %s unless the user writes in another language.", language, language))
+	}
+
+	instructions := persona.Instructions
+	if extra != "" {
+		if instructions == "" {
+			instructions = stringValue(assistant.Instructions)
+		}
+		instructions = strings.TrimSpace(instructions + "\n\n" + extra)
+	}
+	request.Instructions = instructions
+
+	if persona.Tools != nil {
+		request.Tools = make([]openai.Tool, 0, len(persona.Tools))
+		for _, name := range persona.Tools {
+			tool, ok := a.tool(name)
+			if !ok {
+				return openai.RunRequest{}, fmt.Errorf("persona %s: unknown tool %s", persona.Id, name)
+			}
+			request.Tools = append(request.Tools, openai.Tool{Type: openai.ToolTypeFunction, Function: &tool.Definition})
+		}
+	} else {
+		request.Tools = defaultTools(assistant, a.registeredTools())
+	}
+
+	return request, nil
+}
+
+// defaultTools returns the tools of the assistant followed by the registered ones it does not define,
+// tools of a run replace the assistant tools.
+func defaultTools(assistant *openai.Assistant, registered []Tool) []openai.Tool {
+	tools := make([]openai.Tool, 0, len(assistant.Tools)+len(registered))
+	defined := make(map[string]bool)
+	for _, t := range assistant.Tools {
+		tools = append(tools, openai.Tool{Type: openai.ToolType(t.Type), Function: t.Function})
+		if t.Function != nil {
+			defined[t.Function.Name] = true
+		}
+	}
+
+	for i := range registered {
+		definition := &registered[i].Definition
+		if defined[definition.Name] {
+			continue
+		}
+		tools = append(tools, openai.Tool{Type: openai.ToolTypeFunction, Function: definition})
+	}
+	return tools
+}
+
+// retrieveAssistant returns the assistant by id, the configured one if empty.
+func (a *AI) retrieveAssistant(ctx context.Context, id string) (*openai.Assistant, error) {
+	if id == "" {
+		return a.assistant, nil
+	}
+
+	a.mu.RLock()
+	assistant, ok := a.assistants[id]
+	a.mu.RUnlock()
+	if ok {
+		return assistant, nil
+	}
+
+	retrieved, err := a.client.RetrieveAssistant(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	a.assistants[id] = &retrieved
+	a.mu.Unlock()
+
+	return &retrieved, nil
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,154 @@
+package ai
+
+import (
+	"chatgpt/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sashabaranov/go-openai"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+const ToolLogMood = "log_mood"
+
+// ToolCall is the context of a function call made by the assistant.
+type ToolCall struct {
+	UserId       uuid.UUID
+	Conversation string
+	Arguments    string
+}
+
+// Tool is a function the assistant can call during a run.
+type Tool struct {
+	Definition openai.FunctionDefinition
+	// Handle executes the call, the output is given back to the assistant.
+	Handle func(ctx context.Context, call ToolCall) (string, error)
+}
+
+// RegisterTool makes the tool available to personas by its function name.
+func (a *AI) RegisterTool(tool Tool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.tools[tool.Definition.Name] = tool
+}
+
+// registeredTools returns all registered tools ordered by name.
+func (a *AI) registeredTools() []Tool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	tools := make([]Tool, 0, len(a.tools))
+	for _, tool := range a.tools {
+		tools = append(tools, tool)
+	}
+	slices.SortFunc(tools, func(x, y Tool) int { return strings.Compare(x.Definition.Name, y.Definition.Name) })
+	return tools
+}
+
+func (a *AI) tool(name string) (Tool, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	tool, ok := a.tools[name]
+	return tool, ok
+}
+
+// submitToolOutputs runs the requested tools and resumes the run.
+// Tool errors are given to the assistant, so it can tell the user.
+func (a *AI) submitToolOutputs(ctx context.Context, threadId string, run openai.Run, opts RunOptions) (openai.Run, error) {
+	if run.RequiredAction == nil || run.RequiredAction.SubmitToolOutputs == nil {
+		return run, errors.New("run requires unknown action")
+	}
+
+	calls := run.RequiredAction.SubmitToolOutputs.ToolCalls
+	outputs := make([]openai.ToolOutput, 0, len(calls))
+	for _, call := range calls {
+		output, err := a.callTool(ctx, call.Function.Name, ToolCall{
+			UserId:       opts.UserId,
+			Conversation: opts.conversation(threadId),
+			Arguments:    call.Function.Arguments,
+		})
+		if err != nil {
+			slog.WarnContext(ctx, "tool call", "tool", call.Function.Name, "error", err)
+			output = toolError(err)
+		}
+
+		outputs = append(outputs, openai.ToolOutput{ToolCallID: call.ID, Output: output})
+	}
+
+	return a.client.SubmitToolOutputs(ctx, threadId, run.ID, openai.SubmitToolOutputsRequest{ToolOutputs: outputs})
+}
+
+func (a *AI) callTool(ctx context.Context, name string, call ToolCall) (string, error) {
+	tool, ok := a.tool(name)
+	if !ok || tool.Handle == nil {
+		return "", fmt.Errorf("unknown tool %s", name)
+	}
+	return tool.Handle(ctx, call)
+}
+
+func toolError(err error) string {
+	out, _ := json.Marshal(map[string]string{"error": err.Error()})
+	return string(out)
+}
+
+type logMoodArguments struct {
+	Score    int      `json:"score"`
+	Emotions []string `json:"emotions"`
+	Note     string   `json:"note"`
+}
+
+// NewLogMoodTool lets the assistant log a mood entry when the user tells how they feel.
+func NewLogMoodTool(db models.DbClient) Tool {
+	return Tool{
+		Definition: openai.FunctionDefinition{
+			Name: ToolLogMood,
+			Description: "Log the mood of the user when they clearly tell how they feel. " +
+				"Ask before logging if the score is not obvious.",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"score": {"type": "integer", "minimum": 1, "maximum": 10, "description": "Mood from 1 (very bad) to 10 (excellent)"},
+					"emotions": {"type": "array", "items": {"type": "string"}, "description": "Emotions named by the user, in english"},
+					"note": {"type": "string", "description": "Short note in the words of the user"}
+				},
+				"required": ["score"]
+			}`),
+		},
+		Handle: func(ctx context.Context, call ToolCall) (string, error) {
+			if call.UserId == uuid.Nil {
+				return "", errors.New("mood log is available only for registered users")
+			}
+
+			var args logMoodArguments
+			err := json.Unmarshal([]byte(call.Arguments), &args)
+			if err != nil {
+				return "", err
+			}
+
+			fields := models.MoodFields{Score: args.Score, Emotions: args.Emotions, Note: args.Note}
+			err = fields.Validate()
+			if err != nil {
+				return "", err
+			}
+
+			entry := models.MoodEntry{
+				UserId:       call.UserId,
+				Score:        fields.Score,
+				Emotions:     fields.Emotions,
+				Note:         strings.TrimSpace(fields.Note),
+				Source:       models.SourceAssistant,
+				Conversation: call.Conversation,
+			}
+			err = db.Create(ctx, &entry)
+			if err != nil {
+				return "", err
+			}
+
+			return fmt.Sprintf(`{"logged": true, "id": %q}`, entry.Id.String()), nil
+		},
+	}
+}
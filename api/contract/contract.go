@@ -0,0 +1,116 @@
+package contract
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Undocumented routes are served on purpose without an operation in the spec.
+var Undocumented = []string{
+	"GET /",
+	"GET /metrics",
+	"GET /swagger/*any",
+}
+
+var (
+	ginParam  = regexp.MustCompile(`[:*]([A-Za-z0-9_]+)`)
+	versioned = regexp.MustCompile(`^/v[0-9]+/`)
+)
+
+type spec struct {
+	Paths map[string]map[string]struct {
+		Parameters []struct {
+			In   string `json:"in"`
+			Name string `json:"name"`
+		} `json:"parameters"`
+	} `json:"paths"`
+}
+
+// Check compares the operations of the swagger spec with the registered routes and describes every
+// difference: routes missing in the spec, operations without a route and path parameters that differ.
+func Check(doc string, routes gin.RoutesInfo) ([]string, error) {
+	var s spec
+	err := json.Unmarshal([]byte(doc), &s)
+	if err != nil {
+		return nil, fmt.Errorf("spec: %w", err)
+	}
+
+	operations := make(map[string][]string)
+	for path, methods := range s.Paths {
+		for method, operation := range methods {
+			var params []string
+			for _, p := range operation.Parameters {
+				if p.In == "path" {
+					params = append(params, p.Name)
+				}
+			}
+			operations[strings.ToUpper(method)+" "+path] = params
+		}
+	}
+
+	var problems []string
+	routed := make(map[string]bool)
+	for _, route := range routes {
+		key := route.Method + " " + route.Path
+		if slices.Contains(Undocumented, key) {
+			continue
+		}
+
+		// gin writes :id where the spec writes {id}
+		key = route.Method + " " + ginParam.ReplaceAllString(route.Path, "{$1}")
+		routed[key] = true
+
+		params, ok := operations[key]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%v: route is not documented", key))
+			continue
+		}
+
+		var expected []string
+		for _, match := range ginParam.FindAllStringSubmatch(route.Path, -1) {
+			expected = append(expected, match[1])
+		}
+		sort.Strings(expected)
+		sort.Strings(params)
+		if !slices.Equal(expected, params) {
+			problems = append(problems, fmt.Sprintf("%v: documented path parameters %v, route has %v", key, params, expected))
+		}
+	}
+
+	for key := range operations {
+		if !routed[key] {
+			problems = append(problems, fmt.Sprintf("%v: documented operation has no route", key))
+		}
+	}
+
+	sort.Strings(problems)
+	return problems, nil
+}
+
+// CheckAliases reports routes outside the versions that are not aliases of a route under prefix.
+// Undocumented and unversioned routes are served at the root only.
+func CheckAliases(routes gin.RoutesInfo, prefix string, unversioned []string) []string {
+	served := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		served[route.Method+" "+route.Path] = true
+	}
+
+	var problems []string
+	for _, route := range routes {
+		key := route.Method + " " + route.Path
+		if versioned.MatchString(route.Path) || slices.Contains(Undocumented, key) || slices.Contains(unversioned, route.Path) {
+			continue
+		}
+		if !served[route.Method+" "+prefix+route.Path] {
+			problems = append(problems, fmt.Sprintf("%v: route has no version, %v%v is missing", key, prefix, route.Path))
+		}
+	}
+
+	sort.Strings(problems)
+	return problems
+}
@@ -0,0 +1,57 @@
+package contract
+
+import (
+	h "chatgpt/api/handler"
+	"chatgpt/config"
+	s "chatgpt/server"
+	"context"
+	"github.com/gin-gonic/gin"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// routes registers the routes like the contract command, without connecting anywhere. The server registers its
+// specs globally, so it is built once.
+var routes = sync.OnceValue(func() gin.RoutesInfo {
+	gin.SetMode(gin.TestMode)
+
+	configuration := config.Defaults()
+	server := s.NewApiServer(&configuration, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	server.Init(context.Background())
+	h.NewHandler(server).InitRoutes()
+	return server.Router.Routes()
+})
+
+func TestSpecMatchesRoutes(t *testing.T) {
+	for _, version := range s.Versions {
+		prefix := s.VersionPrefix(version)
+
+		var versionRoutes gin.RoutesInfo
+		for _, route := range routes() {
+			if strings.HasPrefix(route.Path, prefix+"/") || slices.Contains(s.Unversioned, route.Path) {
+				versionRoutes = append(versionRoutes, route)
+			}
+		}
+
+		doc, err := s.Spec(version)
+		if err != nil {
+			t.Fatalf("v%v spec: %v", version, err)
+		}
+
+		problems, err := Check(doc, versionRoutes)
+		if err != nil {
+			t.Fatalf("v%v: %v", version, err)
+		}
+		for _, problem := range problems {
+			t.Errorf("v%v: %v", version, problem)
+		}
+	}
+}
+
+func TestRoutesHaveVersions(t *testing.T) {
+	for _, problem := range CheckAliases(routes(), s.VersionPrefix(s.DefaultVersion), s.Unversioned) {
+		t.Error(problem)
+	}
+}
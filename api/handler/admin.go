@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"chatgpt/api/middleware"
+	"chatgpt/models"
+	"chatgpt/server"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"slices"
+	"time"
+)
+
+const RoleAdmin = "admin"
+
+type AdminHandler struct {
+	Server *server.Server
+}
+
+func NewAdminHandler(server *server.Server) *AdminHandler {
+	return &AdminHandler{server}
+}
+
+func (ad *AdminHandler) Init() {
+	ad.Server.Handle(func(r *gin.RouterGroup) {
+		admin := r.Group("/admin", middleware.Authenticate(ad.Server.Cache), middleware.RequireRole(RoleAdmin))
+		admin.GET("/feedback", ad.FeedbackAggregates)
+		admin.GET("/settings", ad.GetSettings)
+		admin.PUT("/settings", ad.UpdateSettings)
+		admin.DELETE("/settings", ad.ResetSettings)
+	})
+}
+
+// FeedbackAggregates godoc
+//
+//	@Summary		Feedback aggregates
+//	@Description	ratings of bot replies grouped by assistant, model and day
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			from	query		string	false	"First day, YYYY-MM-DD, 30 days ago by default"
+//	@Param			to		query		string	false	"Last day, YYYY-MM-DD, today by default"
+//	@Success		200		{object}	[]models.FeedbackAggregate
+//	@Failure		400		{object}	errs.Problem
+//	@Failure		403		{object}	errs.Problem
+//	@Failure		500		{object}	errs.Problem
+//	@Router			/admin/feedback [get]
+func (ad *AdminHandler) FeedbackAggregates(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	from, err := parseDay(c.Query("from"), today.AddDate(0, 0, -30))
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, models.AdvancedErrorResponse{
+			Key:     "from_field",
+			Code:    http.StatusBadRequest,
+			Message: "Поле 'from' должно быть датой в формате YYYY-MM-DD.",
+		})
+		return
+	}
+
+	to, err := parseDay(c.Query("to"), today)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, models.AdvancedErrorResponse{
+			Key:     "to_field",
+			Code:    http.StatusBadRequest,
+			Message: "Поле 'to' должно быть датой в формате YYYY-MM-DD.",
+		})
+		return
+	}
+
+	filter := models.FilterParams{
+		Filter: fmt.Sprintf(`created_at >= '%v' and created_at < '%v'`,
+			from.Format(time.DateOnly), to.AddDate(0, 0, 1).Format(time.DateOnly)),
+		Select: `assistant, model, date_trunc('day', created_at) as day,
+			count(*) as total,
+			count(*) filter (where rating > 0) as up,
+			count(*) filter (where rating < 0) as down,
+			avg(rating)::float as avg_rating`,
+		Group: "assistant, model, day",
+		FeedParams: models.FeedParams{
+			Orderings: "day desc, assistant, model",
+		},
+	}
+
+	aggregates := make([]models.FeedbackAggregate, 0)
+	err = ad.Server.Db.Select(ctx, "feedbacks", filter, &aggregates)
+	if models.AllowErrNotFound(err) != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, aggregates)
+}
+
+// GetSettings godoc
+//
+//	@Summary		Runtime settings
+//	@Description	settings in effect on this replica, their source and version
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	models.EffectiveSettings
+//	@Failure		403	{object}	errs.Problem
+//	@Router			/admin/settings [get]
+func (ad *AdminHandler) GetSettings(c *gin.Context) {
+	c.JSON(http.StatusOK, ad.Server.Settings.Current())
+}
+
+// UpdateSettings godoc
+//
+//	@Summary		Replace runtime settings
+//	@Description	stores the settings in Redis in place of the config ones, all replicas apply them at once
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			rq	body		models.Settings	true	"Settings"
+//	@Success		200	{object}	models.EffectiveSettings
+//	@Failure		400	{object}	errs.Problem
+//	@Failure		403	{object}	errs.Problem
+//	@Failure		500	{object}	errs.Problem
+//	@Router			/admin/settings [put]
+func (ad *AdminHandler) UpdateSettings(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var input models.Settings
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	err = input.Validate()
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	if input.DefaultPersona != "" && !slices.ContainsFunc(input.Personas, func(p models.Persona) bool { return p.Id == input.DefaultPersona }) {
+		_, err = ad.Server.AI.Persona(input.DefaultPersona)
+		if err != nil {
+			c.AbortWithError(http.StatusBadRequest, models.AdvancedErrorResponse{
+				Key:     "default_persona_field",
+				Code:    http.StatusBadRequest,
+				Message: "Поле 'defaultPersona' содержит неизвестную персону.",
+			})
+			return
+		}
+	}
+
+	if ad.Server.Configuration.CorsAllowCredentials && slices.Contains(input.CorsOrigins, "*") {
+		c.AbortWithError(http.StatusBadRequest, models.AdvancedErrorResponse{
+			Key:     "cors_credentials_field",
+			Code:    http.StatusBadRequest,
+			Message: "Поле 'corsOrigins' не может содержать * при включенных учетных данных CORS.",
+		})
+		return
+	}
+
+	err = ad.Server.Settings.Save(ctx, input)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ad.Server.Settings.Current())
+}
+
+// ResetSettings godoc
+//
+//	@Summary		Reset runtime settings
+//	@Description	removes the stored settings, all replicas go back to the settings of their config
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	models.EffectiveSettings
+//	@Failure		403	{object}	errs.Problem
+//	@Failure		500	{object}	errs.Problem
+//	@Router			/admin/settings [delete]
+func (ad *AdminHandler) ResetSettings(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	err := ad.Server.Settings.Reset(ctx)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ad.Server.Settings.Current())
+}
+
+func parseDay(value string, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
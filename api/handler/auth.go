@@ -1,10 +1,12 @@
 package handler
 
 import (
+	"chatgpt/api/middleware"
 	"chatgpt/auth"
+	"chatgpt/errs"
 	"chatgpt/models"
 	"chatgpt/server"
-	"errors"
+	"context"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/sashabaranov/go-openai"
@@ -20,14 +22,22 @@ func NewAuthHandler(server *server.Server) *AuthHandler {
 	return &AuthHandler{server}
 }
 
-func (a *AuthHandler) Init() {
-	a.Server.Router.POST("/register", a.Register)
-	//a.Server.Router.POST("/verify", a.Verify)
-	a.Server.Router.POST("/auth/phone", a.LoginPhone)
-	a.Server.Router.POST("/auth/email", a.LoginEmail)
-	a.Server.Router.GET("/token/refresh/:token", a.Refresh)
+// authBodyLimit bounds the credentials bodies, Idempotency reads them whole.
+const authBodyLimit = 16 << 10
 
-	a.Server.Router.POST("/auth/firebase", a.FirebaseAuth)
+func (a *AuthHandler) Init() {
+	limit := middleware.BodyLimit(authBodyLimit)
+	idempotent := middleware.Idempotency(a.Server.Cache, time.Duration(a.Server.Configuration.IdempotencyTtlHours)*time.Hour)
+
+	a.Server.Handle(func(r *gin.RouterGroup) {
+		r.POST("/register", limit, idempotent, a.Register)
+		//r.POST("/verify", a.Verify)
+		r.POST("/auth/phone", limit, idempotent, a.LoginPhone)
+		r.POST("/auth/email", limit, idempotent, a.LoginEmail)
+		r.GET("/token/refresh/:token", a.Refresh)
+
+		r.POST("/auth/firebase", limit, idempotent, a.FirebaseAuth)
+	})
 }
 
 type TokenResponse struct {
@@ -42,10 +52,13 @@ type TokenResponse struct {
 //	@Tags			auth
 //	@Accept			json
 //	@Produce		json
-//	@Param			rq	body		models.AuthorizationFields	true	"Input data"
-//	@Success		200	{object}	TokenResponse
-//	@Failure		400	{object}	models.ErrorResponse
-//	@Failure		500	{object}	models.ErrorResponse
+//	@Param			rq				body		models.AuthorizationFields	true	"Input data"
+//	@Param			Idempotency-Key	header		string						false	"Unique key of the request, retries with the same key get the first response replayed"
+//	@Success		200				{object}	TokenResponse
+//	@Failure		400				{object}	errs.Problem
+//	@Failure		409				{object}	errs.Problem
+//	@Failure		422				{object}	errs.Problem
+//	@Failure		500				{object}	errs.Problem
 //	@Router			/register [post]
 func (a *AuthHandler) Register(c *gin.Context) {
 	ctx := c.Request.Context()
@@ -53,7 +66,7 @@ func (a *AuthHandler) Register(c *gin.Context) {
 	var input models.AuthorizationFields
 	err := c.ShouldBind(&input)
 	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
+		c.AbortWithError(http.StatusBadRequest, invalidBody(err))
 		return
 	}
 
@@ -73,7 +86,7 @@ func (a *AuthHandler) Register(c *gin.Context) {
 	var user models.User
 	err = a.Server.Db.Get(ctx, filter, &user)
 	if !models.IsErrNotFound(err) && len(user.Password) > 0 {
-		c.AbortWithError(http.StatusBadRequest, errors.New("user already registered"))
+		c.AbortWithError(http.StatusConflict, errs.New(errs.CodeConflict, "user_exists", "user already registered"))
 		return
 	} else if models.AllowErrNotFound(err) != nil {
 		c.AbortWithError(http.StatusInternalServerError, err)
@@ -81,7 +94,7 @@ func (a *AuthHandler) Register(c *gin.Context) {
 	}
 
 	if input.Password != input.RePassword {
-		c.AbortWithError(http.StatusBadRequest, errors.New("passwords not same"))
+		c.AbortWithError(http.StatusBadRequest, errs.New(errs.CodeValidation, "password_field", "passwords not same"))
 		return
 	}
 
@@ -110,13 +123,7 @@ func (a *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	err = a.Server.Cache.SetHash(ctx, auth.RedisAccessPath+access.Plaintext, user, 24*time.Hour)
-	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
-		return
-	}
-
-	err = a.Server.Cache.SetHash(ctx, auth.RedisRefreshPath+refresh.Plaintext, user, 7*24*time.Hour)
+	err = auth.StoreAuthTokens(ctx, a.Server.Cache, user, access, refresh)
 	if err != nil {
 		c.AbortWithError(http.StatusInternalServerError, err)
 		return
@@ -138,10 +145,13 @@ func (a *AuthHandler) Register(c *gin.Context) {
 //	@Tags			auth
 //	@Accept			json
 //	@Produce		json
-//	@Param			rq	body		models.AuthorizationFields	true	"Fill in only phone and password"
-//	@Success		200	{object}	TokenResponse
-//	@Failure		400	{object}	models.AdvancedErrorResponse
-//	@Failure		500	{object}	models.ErrorResponse
+//	@Param			rq				body		models.AuthorizationFields	true	"Fill in only phone and password"
+//	@Param			Idempotency-Key	header		string						false	"Unique key of the request, retries with the same key get the first response replayed"
+//	@Success		200				{object}	TokenResponse
+//	@Failure		400				{object}	errs.Problem
+//	@Failure		409				{object}	errs.Problem
+//	@Failure		422				{object}	errs.Problem
+//	@Failure		500				{object}	errs.Problem
 //	@Router			/auth/phone [post]
 func (a *AuthHandler) LoginPhone(c *gin.Context) {
 	ctx := c.Request.Context()
@@ -149,7 +159,7 @@ func (a *AuthHandler) LoginPhone(c *gin.Context) {
 	var input models.AuthorizationFields
 	err := c.ShouldBind(&input)
 	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
+		c.AbortWithError(http.StatusBadRequest, invalidBody(err))
 		return
 	}
 
@@ -179,6 +189,12 @@ func (a *AuthHandler) LoginPhone(c *gin.Context) {
 		return
 	}
 
+	err = a.restoreAccount(ctx, &user)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
 	access, refresh, err := auth.GetAuthTokens(user.Id.String(), a.Server.Configuration.SecretKeyAccess, a.Server.Configuration.SecretKeyRefresh)
 	if err != nil {
 		c.AbortWithError(http.StatusInternalServerError, err)
@@ -192,13 +208,7 @@ func (a *AuthHandler) LoginPhone(c *gin.Context) {
 	//	return
 	//}
 
-	err = a.Server.Cache.SetHash(ctx, auth.RedisAccessPath+access.Plaintext, user, 24*time.Hour)
-	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
-		return
-	}
-
-	err = a.Server.Cache.SetHash(ctx, auth.RedisRefreshPath+refresh.Plaintext, user, 7*24*time.Hour)
+	err = auth.StoreAuthTokens(ctx, a.Server.Cache, user, access, refresh)
 	if err != nil {
 		c.AbortWithError(http.StatusInternalServerError, err)
 		return
@@ -214,10 +224,13 @@ func (a *AuthHandler) LoginPhone(c *gin.Context) {
 //	@Tags			auth
 //	@Accept			json
 //	@Produce		json
-//	@Param			rq	body		models.AuthorizationFields	true	"Fill in only email and password"
-//	@Success		200	{object}	TokenResponse
-//	@Failure		400	{object}	models.AdvancedErrorResponse
-//	@Failure		500	{object}	models.ErrorResponse
+//	@Param			rq				body		models.AuthorizationFields	true	"Fill in only email and password"
+//	@Param			Idempotency-Key	header		string						false	"Unique key of the request, retries with the same key get the first response replayed"
+//	@Success		200				{object}	TokenResponse
+//	@Failure		400				{object}	errs.Problem
+//	@Failure		409				{object}	errs.Problem
+//	@Failure		422				{object}	errs.Problem
+//	@Failure		500				{object}	errs.Problem
 //	@Router			/auth/email [post]
 func (a *AuthHandler) LoginEmail(c *gin.Context) {
 	ctx := c.Request.Context()
@@ -225,7 +238,7 @@ func (a *AuthHandler) LoginEmail(c *gin.Context) {
 	var input models.AuthorizationFields
 	err := c.ShouldBind(&input)
 	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
+		c.AbortWithError(http.StatusBadRequest, invalidBody(err))
 		return
 	}
 
@@ -255,6 +268,12 @@ func (a *AuthHandler) LoginEmail(c *gin.Context) {
 		return
 	}
 
+	err = a.restoreAccount(ctx, &user)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
 	access, refresh, err := auth.GetAuthTokens(user.Id.String(), a.Server.Configuration.SecretKeyAccess, a.Server.Configuration.SecretKeyRefresh)
 	if err != nil {
 		c.AbortWithError(http.StatusInternalServerError, err)
@@ -268,13 +287,7 @@ func (a *AuthHandler) LoginEmail(c *gin.Context) {
 	//	return
 	//}
 
-	err = a.Server.Cache.SetHash(ctx, auth.RedisAccessPath+access.Plaintext, user, 24*time.Hour)
-	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
-		return
-	}
-
-	err = a.Server.Cache.SetHash(ctx, auth.RedisRefreshPath+refresh.Plaintext, user, 7*24*time.Hour)
+	err = auth.StoreAuthTokens(ctx, a.Server.Cache, user, access, refresh)
 	if err != nil {
 		c.AbortWithError(http.StatusInternalServerError, err)
 		return
@@ -290,10 +303,13 @@ func (a *AuthHandler) LoginEmail(c *gin.Context) {
 //	@Tags			auth
 //	@Accept			json
 //	@Produce		json
-//	@Param			rq	body		models.FirebaseAuthFields	true	"Input data"
-//	@Success		200	{object}	TokenResponse
-//	@Failure		400	{object}	models.AdvancedErrorResponse
-//	@Failure		500	{object}	models.ErrorResponse
+//	@Param			rq				body		models.FirebaseAuthFields	true	"Input data"
+//	@Param			Idempotency-Key	header		string						false	"Unique key of the request, retries with the same key get the first response replayed"
+//	@Success		200				{object}	TokenResponse
+//	@Failure		400				{object}	errs.Problem
+//	@Failure		409				{object}	errs.Problem
+//	@Failure		422				{object}	errs.Problem
+//	@Failure		500				{object}	errs.Problem
 //	@Router			/auth/firebase [post]
 func (a *AuthHandler) FirebaseAuth(c *gin.Context) {
 	ctx := c.Request.Context()
@@ -301,7 +317,7 @@ func (a *AuthHandler) FirebaseAuth(c *gin.Context) {
 	var input models.FirebaseAuthFields
 	err := c.ShouldBind(&input)
 	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
+		c.AbortWithError(http.StatusBadRequest, invalidBody(err))
 		return
 	}
 
@@ -361,19 +377,19 @@ func (a *AuthHandler) FirebaseAuth(c *gin.Context) {
 		}
 	}
 
-	access, refresh, err := auth.GetAuthTokens(user.Id.String(), a.Server.Configuration.SecretKeyAccess, a.Server.Configuration.SecretKeyRefresh)
+	err = a.restoreAccount(ctx, &user)
 	if err != nil {
 		c.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
 
-	err = a.Server.Cache.SetHash(ctx, auth.RedisAccessPath+access.Plaintext, user, 24*time.Hour)
+	access, refresh, err := auth.GetAuthTokens(user.Id.String(), a.Server.Configuration.SecretKeyAccess, a.Server.Configuration.SecretKeyRefresh)
 	if err != nil {
 		c.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
 
-	err = a.Server.Cache.SetHash(ctx, auth.RedisRefreshPath+refresh.Plaintext, user, 7*24*time.Hour)
+	err = auth.StoreAuthTokens(ctx, a.Server.Cache, user, access, refresh)
 	if err != nil {
 		c.AbortWithError(http.StatusInternalServerError, err)
 		return
@@ -388,6 +404,20 @@ func (a *AuthHandler) FirebaseAuth(c *gin.Context) {
 	c.JSON(http.StatusOK, TokenResponse{access.Plaintext, refresh.Plaintext})
 }
 
+// restoreAccount cancels the scheduled deletion of the account, logging in during the grace period keeps it.
+func (a *AuthHandler) restoreAccount(ctx context.Context, user *models.User) error {
+	if user.DeleteAt == nil {
+		return nil
+	}
+
+	var filter models.FilterParams
+	filter.Filter = fmt.Sprintf(`id = '%v'`, user.Id.String())
+
+	// Update skips nil fields, the whole row is replaced to clear the deletion time.
+	user.DeleteAt = nil
+	return a.Server.Db.Replace(ctx, filter, user)
+}
+
 // Refresh godoc
 //
 //	@Summary		Refresh tokens
@@ -395,11 +425,11 @@ func (a *AuthHandler) FirebaseAuth(c *gin.Context) {
 //	@Tags			auth
 //	@Accept			json
 //	@Produce		json
-//	@Param			refreshToken	path		string	true	"Refresh Token"
-//	@Success		200				{object}	TokenResponse
-//	@Failure		400				{object}	models.AdvancedErrorResponse
-//	@Failure		500				{object}	models.ErrorResponse
-//	@Router			/token/refresh/:refreshToken [get]
+//	@Param			token	path		string	true	"Refresh Token"
+//	@Success		200		{object}	TokenResponse
+//	@Failure		400		{object}	errs.Problem
+//	@Failure		500		{object}	errs.Problem
+//	@Router			/token/refresh/{token} [get]
 func (a *AuthHandler) Refresh(c *gin.Context) {
 	ctx := c.Request.Context()
 
@@ -407,8 +437,11 @@ func (a *AuthHandler) Refresh(c *gin.Context) {
 
 	var user models.User
 	err := a.Server.Cache.GetHash(ctx, auth.RedisRefreshPath+token, &user)
-	if err != nil {
-		c.AbortWithError(http.StatusUnauthorized, err)
+	if models.IsErrNotFound(err) {
+		c.AbortWithError(http.StatusUnauthorized, errs.ErrInvalidToken)
+		return
+	} else if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
 
@@ -435,13 +468,7 @@ func (a *AuthHandler) Refresh(c *gin.Context) {
 	//	return
 	//}
 
-	err = a.Server.Cache.SetHash(ctx, auth.RedisAccessPath+access.Plaintext, user, 24*time.Hour)
-	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
-		return
-	}
-
-	err = a.Server.Cache.SetHash(ctx, auth.RedisRefreshPath+refresh.Plaintext, user, 7*24*time.Hour)
+	err = auth.StoreAuthTokens(ctx, a.Server.Cache, user, access, refresh)
 	if err != nil {
 		c.AbortWithError(http.StatusInternalServerError, err)
 		return
@@ -0,0 +1,448 @@
+package handler
+
+import (
+	"chatgpt/api/middleware"
+	"chatgpt/errs"
+	"chatgpt/models"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/sashabaranov/go-openai"
+	"net/http"
+	"strings"
+)
+
+var (
+	errConversationNotFound = errs.New(errs.CodeNotFound, "conversation_not_found", "conversation not found")
+	errMessageNotFound      = errs.New(errs.CodeNotFound, "message_not_found", "message not found")
+)
+
+// conversationOwner loads the user and checks that the conversation belongs to them.
+func (ch *ChatHandler) conversationOwner(ctx context.Context, cacheUser models.User, id string) (models.User, error) {
+	var filter models.FilterParams
+	filter.Filter = fmt.Sprintf(`id = '%v'`, cacheUser.Id.String())
+
+	var user models.User
+	err := ch.Server.Db.Get(ctx, filter, &user)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if id == "" {
+		return user, errConversationNotFound
+	}
+	if id == user.Thread {
+		return user, nil
+	}
+
+	filter.Filter = fmt.Sprintf(`id = '%v' and user_id = '%v'`, strings.ReplaceAll(id, "'", "''"), user.Id.String())
+
+	var conversation models.Conversation
+	err = ch.Server.Db.Get(ctx, filter, &conversation)
+	if models.IsErrNotFound(err) {
+		return user, errConversationNotFound
+	}
+
+	return user, err
+}
+
+// ownedConversation loads the conversation of the path parameter id, it aborts the request if that fails.
+func (ch *ChatHandler) ownedConversation(c *gin.Context) (models.User, models.Conversation, bool) {
+	ctx := c.Request.Context()
+
+	cacheUser, ok := c.Get("user")
+	if !ok {
+		c.AbortWithError(http.StatusUnauthorized, errs.ErrUnauthorized)
+		return models.User{}, models.Conversation{}, false
+	}
+
+	id := c.Param("id")
+	user, err := ch.conversationOwner(ctx, cacheUser.(models.User), id)
+	if errors.Is(err, errConversationNotFound) {
+		c.AbortWithError(http.StatusNotFound, err)
+		return models.User{}, models.Conversation{}, false
+	} else if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return models.User{}, models.Conversation{}, false
+	}
+
+	conversation, err := models.GetConversation(ctx, ch.Server.Db, id, user.Id)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return models.User{}, models.Conversation{}, false
+	}
+
+	return user, conversation, true
+}
+
+// lastMessage returns the newest stored message, the leaf of conversations without a stored one.
+func (ch *ChatHandler) lastMessage(ctx context.Context, conversation string, userId string) (models.Message, error) {
+	var filter models.FilterParams
+	filter.Filter = fmt.Sprintf(`conversation = '%v' and user_id = '%v'`, conversation, userId)
+	filter.Orderings = "created_at desc"
+	filter.Limit = 1
+
+	var message models.Message
+	err := ch.Server.Db.Get(ctx, filter, &message)
+	return message, err
+}
+
+// leaf returns the ID of the last message of the active branch, empty if there are no messages.
+func (ch *ChatHandler) leaf(ctx context.Context, conversation models.Conversation) (string, error) {
+	if conversation.Leaf != "" {
+		return conversation.Leaf, nil
+	}
+
+	last, err := ch.lastMessage(ctx, conversation.Id, conversation.UserId.String())
+	return last.Id, models.AllowErrNotFound(err)
+}
+
+// lastQuestion returns the index of the user message the last reply of the branch answers, -1 if there is none.
+func lastQuestion(branch []models.Message) int {
+	i := len(branch) - 1
+	if i >= 0 && branch[i].Role != openai.ChatMessageRoleUser {
+		i--
+	}
+	if i < 0 || branch[i].Role != openai.ChatMessageRoleUser {
+		return -1
+	}
+	return i
+}
+
+// branchMessages returns the messages of the active branch. A forked branch is read from the local copy,
+// the copied messages have other IDs in the forked thread.
+func (ch *ChatHandler) branchMessages(ctx context.Context, conversation models.Conversation) ([]models.Message, error) {
+	if conversation.Thread == "" {
+		return ch.Server.AI.GetAllMessages(ctx, conversation.Id)
+	}
+	return models.Branch(ctx, ch.Server.Db, conversation)
+}
+
+// retireThread deletes a forked thread that no longer holds the active branch, the own thread of the
+// conversation is kept. A failure only leaves the thread behind.
+func (ch *ChatHandler) retireThread(ctx context.Context, conversation models.Conversation, thread string) {
+	if thread == "" || thread == conversation.Id {
+		return
+	}
+
+	err := ch.Server.AI.DeleteThread(context.WithoutCancel(ctx), thread)
+	if err != nil {
+		ch.Server.Logger.WarnContext(ctx, "delete forked thread", "thread", thread, "error", err)
+	}
+}
+
+// runBranch runs the assistant on a new thread with the branch messages followed by the text, if set, and
+// makes it the active branch. It returns the stored new messages, the text one first if set.
+func (ch *ChatHandler) runBranch(c *gin.Context, user models.User, conversation models.Conversation, branch []models.Message, text string) ([]models.Message, error) {
+	ctx := c.Request.Context()
+
+	forked, err := ch.Server.AI.ForkThread(ctx, branch)
+	if err != nil {
+		return nil, err
+	}
+
+	var added []models.Message
+	if text != "" {
+		question, err := ch.Server.AI.AddMessage(ctx, forked.ID, text)
+		if err != nil {
+			ch.retireThread(ctx, conversation, forked.ID)
+			return nil, err
+		}
+		added = append(added, question)
+	}
+
+	opts := ch.runOptions(ctx, user.Id, conversation.Id, middleware.Locale(c))
+	answer, err := ch.Server.AI.Run(ctx, forked.ID, opts)
+	if err != nil {
+		ch.retireThread(ctx, conversation, forked.ID)
+		return nil, err
+	}
+	added = append(added, answer)
+
+	parentId := ""
+	if len(branch) > 0 {
+		parentId = branch[len(branch)-1].Id
+	}
+
+	previous := conversation.Thread
+	conversation.Thread = forked.ID
+	added, saved := ch.storeMessages(ctx, user, conversation, parentId, added...)
+	if saved {
+		ch.retireThread(ctx, conversation, previous)
+	} else {
+		ch.retireThread(ctx, conversation, forked.ID)
+	}
+
+	return added, nil
+}
+
+// ConversationTree godoc
+//
+//	@Summary		Get conversation tree
+//	@Description	get all stored messages of the conversation with parent links, regenerated replies and edited messages are siblings
+//	@Tags			chat
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		string	true	"Conversation ID"
+//	@Success		200	{object}	[]models.Message
+//	@Failure		404	{object}	errs.Problem
+//	@Failure		500	{object}	errs.Problem
+//	@Router			/chat/conversations/{id}/tree [get]
+func (ch *ChatHandler) ConversationTree(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	cacheUser, ok := c.Get("user")
+	if !ok {
+		c.AbortWithError(http.StatusUnauthorized, errs.ErrUnauthorized)
+		return
+	}
+
+	id := c.Param("id")
+	_, err := ch.conversationOwner(ctx, cacheUser.(models.User), id)
+	if errors.Is(err, errConversationNotFound) {
+		c.AbortWithError(http.StatusNotFound, err)
+		return
+	} else if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	var filter models.FilterParams
+	filter.Filter = fmt.Sprintf(`conversation = '%v' and user_id = '%v'`, id, cacheUser.(models.User).Id.String())
+	filter.Orderings = "created_at"
+
+	messages := make([]models.Message, 0)
+	err = ch.Server.Db.Get(ctx, filter, &messages)
+	if models.AllowErrNotFound(err) != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, messages)
+}
+
+// Regenerate godoc
+//
+//	@Summary		Regenerate last reply
+//	@Description	re-runs the bot for the last user message, the new reply is stored next to the previous ones
+//	@Tags			chat
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id				path		string			true	"Conversation ID"
+//	@Param			Idempotency-Key	header		string			false	"Unique key of the request, retries with the same key get the first response replayed"
+//	@Success		200				{object}	models.Message	"Alternative reply"
+//	@Failure		400				{object}	errs.Problem
+//	@Failure		404				{object}	errs.Problem
+//	@Failure		409				{object}	errs.Problem
+//	@Failure		422				{object}	errs.Problem
+//	@Failure		500				{object}	errs.Problem
+//	@Router			/chat/conversations/{id}/regenerate [post]
+func (ch *ChatHandler) Regenerate(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	user, conversation, ok := ch.ownedConversation(c)
+	if !ok {
+		return
+	}
+
+	branch, err := models.Branch(ctx, ch.Server.Db, conversation)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	question := lastQuestion(branch)
+	if question < 0 {
+		c.AbortWithError(http.StatusBadRequest, models.AdvancedErrorResponse{
+			Key:     "conversation",
+			Code:    http.StatusBadRequest,
+			Message: "В переписке нет сообщения, на которое можно ответить заново.",
+		})
+		return
+	}
+
+	// the new reply sees the branch up to the question, not the reply it replaces
+	added, err := ch.runBranch(c, user, conversation, branch[:question+1], "")
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, added[0])
+}
+
+type EditMessageResponse struct {
+	Message models.Message `json:"message"`
+	Reply   models.Message `json:"reply"`
+}
+
+// EditMessage godoc
+//
+//	@Summary		Edit last message
+//	@Description	replaces the last user message with the new text and re-runs the bot, both versions are kept as branches
+//	@Tags			chat
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id		path		string					true	"Conversation ID"
+//	@Param			message	path		string					true	"ID of the last user message"
+//	@Param			rq		body		models.MessageFields	true	"New message text"
+//	@Success		200		{object}	EditMessageResponse
+//	@Failure		400		{object}	errs.Problem
+//	@Failure		404		{object}	errs.Problem
+//	@Failure		500		{object}	errs.Problem
+//	@Router			/chat/conversations/{id}/messages/{message} [patch]
+func (ch *ChatHandler) EditMessage(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var input models.MessageFields
+	err := c.ShouldBind(&input)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, invalidBody(err))
+		return
+	}
+
+	if strings.TrimSpace(input.Text) == "" {
+		c.AbortWithError(http.StatusBadRequest, models.AdvancedErrorResponse{
+			Key:     "text_field",
+			Code:    http.StatusBadRequest,
+			Message: "Поле 'text' должно быть заполнено.",
+		})
+		return
+	}
+
+	user, conversation, ok := ch.ownedConversation(c)
+	if !ok {
+		return
+	}
+
+	branch, err := models.Branch(ctx, ch.Server.Db, conversation)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	question := lastQuestion(branch)
+	if question < 0 || branch[question].Id != c.Param("message") {
+		c.AbortWithError(http.StatusBadRequest, models.AdvancedErrorResponse{
+			Key:     "message_id",
+			Code:    http.StatusBadRequest,
+			Message: "Изменить можно только последнее сообщение.",
+		})
+		return
+	}
+
+	// the edited message replaces the original one, the reply sees only the branch before it
+	added, err := ch.runBranch(c, user, conversation, branch[:question], input.Text)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, EditMessageResponse{added[0], added[1]})
+}
+
+// GetBranch godoc
+//
+//	@Summary		Get active branch
+//	@Description	get messages of the active branch of the conversation from the first one to its leaf
+//	@Tags			chat
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		string	true	"Conversation ID"
+//	@Success		200	{object}	[]models.Message
+//	@Failure		404	{object}	errs.Problem
+//	@Failure		500	{object}	errs.Problem
+//	@Router			/chat/conversations/{id}/branch [get]
+func (ch *ChatHandler) GetBranch(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	_, conversation, ok := ch.ownedConversation(c)
+	if !ok {
+		return
+	}
+
+	branch, err := models.Branch(ctx, ch.Server.Db, conversation)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, branch)
+}
+
+// SwitchBranch godoc
+//
+//	@Summary		Switch active branch
+//	@Description	makes the branch ending with the message active, new messages, regenerated replies and edits continue it
+//	@Tags			chat
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		string				true	"Conversation ID"
+//	@Param			rq	body		models.BranchFields	true	"Leaf of the branch, any message of the conversation tree"
+//	@Success		200	{object}	[]models.Message	"Messages of the branch"
+//	@Failure		400	{object}	errs.Problem
+//	@Failure		404	{object}	errs.Problem
+//	@Failure		500	{object}	errs.Problem
+//	@Router			/chat/conversations/{id}/branch [put]
+func (ch *ChatHandler) SwitchBranch(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var input models.BranchFields
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	_, conversation, ok := ch.ownedConversation(c)
+	if !ok {
+		return
+	}
+
+	messages, err := models.ConversationMessages(ctx, ch.Server.Db, conversation)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	branch := models.Path(messages, input.Leaf)
+	if len(branch) == 0 {
+		c.AbortWithError(http.StatusNotFound, errMessageNotFound)
+		return
+	}
+
+	leaf, err := ch.leaf(ctx, conversation)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	if leaf == input.Leaf {
+		c.JSON(http.StatusOK, branch)
+		return
+	}
+
+	forked, err := ch.Server.AI.ForkThread(ctx, branch)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	previous := conversation.Thread
+	conversation.Thread = forked.ID
+	conversation.Leaf = input.Leaf
+	err = models.SaveBranch(ctx, ch.Server.Db, conversation)
+	if err != nil {
+		ch.retireThread(ctx, conversation, forked.ID)
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	ch.retireThread(ctx, conversation, previous)
+
+	c.JSON(http.StatusOK, branch)
+}
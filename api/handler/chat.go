@@ -1,14 +1,21 @@
 package handler
 
 import (
+	"bytes"
+	"chatgpt/ai"
 	"chatgpt/api/middleware"
+	a "chatgpt/auth"
+	"chatgpt/errs"
 	"chatgpt/models"
+	"chatgpt/search"
 	"chatgpt/server"
+	"chatgpt/transcript"
 	"context"
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"io"
 	"net/http"
 	"slices"
 	"strings"
@@ -16,7 +23,15 @@ import (
 )
 
 const (
-	RedisThread = "thread/"
+	RedisThread = a.RedisThreadPath
+
+	SearchSizeDefault = 20
+	// messagesPageSize is the count of latest messages returned, as OpenAI lists by default.
+	messagesPageSize = 20
+
+	replyPushTitle = "TheraChat"
+	// replyPushLength limits the reply text in the push, the whole reply is loaded from the conversation.
+	replyPushLength = 200
 )
 
 type ChatHandler struct {
@@ -28,36 +43,64 @@ func NewChatHandler(server *server.Server) *ChatHandler {
 }
 
 func (ch *ChatHandler) Init() {
-	chat := ch.Server.Router.Group("/chat")
-	auth := chat.Group("", middleware.Authenticate(ch.Server.Cache))
-	auth.POST("/start", ch.StartChat)
-	auth.POST("/message", ch.WriteChatMessage)
-	auth.GET("/messages", ch.GetChatMessages)
-
-	anon := chat.Group("/anon")
-	anon.POST("/start", ch.StartAnonChat)
-	anon.POST("/:id/message", ch.WriteAnonChatMessage)
-	anon.GET("/:id/messages", ch.GetAnonChatMessages)
+	idempotent := middleware.Idempotency(ch.Server.Cache, time.Duration(ch.Server.Configuration.IdempotencyTtlHours)*time.Hour)
+
+	ch.Server.Handle(func(r *gin.RouterGroup) {
+		chat := r.Group("/chat", middleware.BodyLimit(ch.Server.Configuration.ChatBodyLimitBytes))
+		chat.GET("/personas", ch.GetPersonas)
+		auth := chat.Group("", middleware.Authenticate(ch.Server.Cache))
+		auth.POST("/start", idempotent, ch.StartChat)
+		auth.POST("/message", idempotent, ch.WriteChatMessage)
+		auth.GET("/messages", ch.GetChatMessages)
+		auth.GET("/search", ch.Search)
+		auth.POST("/messages/:id/feedback", idempotent, ch.Feedback)
+		auth.GET("/conversations/:id/export", ch.ExportConversation)
+		auth.GET("/conversations/:id/tree", ch.ConversationTree)
+		auth.POST("/conversations/:id/regenerate", idempotent, ch.Regenerate)
+		auth.PATCH("/conversations/:id/messages/:message", ch.EditMessage)
+		auth.GET("/conversations/:id/branch", ch.GetBranch)
+		auth.PUT("/conversations/:id/branch", ch.SwitchBranch)
+
+		anon := chat.Group("/anon")
+		anon.POST("/start", idempotent, ch.StartAnonChat)
+		anon.POST("/:id/message", idempotent, ch.WriteAnonChatMessage)
+		anon.GET("/:id/messages", ch.GetAnonChatMessages)
+	})
+}
+
+type StartChatResponse struct {
+	Result  string `json:"result"`
+	Id      string `json:"id"`
+	Persona string `json:"persona"`
 }
 
 // StartChat godoc
 //
 //	@Summary		Start new chat
-//	@Description	starts chat with ChatGPT with authorized user
+//	@Description	starts chat with ChatGPT with authorized user, the persona is the default one if not set
 //	@Tags			chat
 //	@Accept			json
 //	@Produce		json
 //	@Security		BearerAuth
-//	@Success		200	{object}	Response
-//	@Failure		400	{object}	models.AdvancedErrorResponse
-//	@Failure		500	{object}	models.ErrorResponse
+//	@Param			rq				body		models.StartChatFields	false	"Persona of the conversation"
+//	@Param			Idempotency-Key	header		string					false	"Unique key of the request, retries with the same key get the first response replayed"
+//	@Success		200				{object}	StartChatResponse
+//	@Failure		400				{object}	errs.Problem
+//	@Failure		409				{object}	errs.Problem
+//	@Failure		422				{object}	errs.Problem
+//	@Failure		500				{object}	errs.Problem
 //	@Router			/chat/start [post]
 func (ch *ChatHandler) StartChat(c *gin.Context) {
 	ctx := c.Request.Context()
 
 	cacheUser, ok := c.Get("user")
 	if !ok {
-		c.AbortWithError(http.StatusUnauthorized, errors.New("not authorized"))
+		c.AbortWithError(http.StatusUnauthorized, errs.ErrUnauthorized)
+		return
+	}
+
+	persona, ok := ch.bindPersona(c)
+	if !ok {
 		return
 	}
 
@@ -67,6 +110,16 @@ func (ch *ChatHandler) StartChat(c *gin.Context) {
 		return
 	}
 
+	err = ch.Server.Db.Create(ctx, &models.Conversation{
+		Id:      thread.ID,
+		UserId:  cacheUser.(models.User).Id,
+		Persona: persona.Id,
+	})
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
 	var filter models.FilterParams
 	filter.Filter = fmt.Sprintf(`id = '%v'`, cacheUser.(models.User).Id.String())
 
@@ -83,35 +136,103 @@ func (ch *ChatHandler) StartChat(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, Response{"chat started"})
+	c.JSON(http.StatusOK, StartChatResponse{"chat started", thread.ID, persona.Id})
+}
+
+// bindPersona reads the optional persona of a new conversation.
+func (ch *ChatHandler) bindPersona(c *gin.Context) (models.Persona, bool) {
+	var input models.StartChatFields
+	err := c.ShouldBind(&input)
+	if err != nil && !errors.Is(err, io.EOF) {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return models.Persona{}, false
+	}
+
+	persona, err := ch.Server.AI.Persona(input.Persona)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, models.AdvancedErrorResponse{
+			Key:     "persona_field",
+			Code:    http.StatusBadRequest,
+			Message: "Поле 'persona' содержит неизвестную персону.",
+		})
+		return models.Persona{}, false
+	}
+
+	return persona, true
+}
+
+// runOptions returns the run setup of the conversation persona and the user memory.
+func (ch *ChatHandler) runOptions(ctx context.Context, userId uuid.UUID, conversation string, locale string) ai.RunOptions {
+	var filter models.FilterParams
+	filter.Filter = fmt.Sprintf(`id = '%v'`, conversation)
+
+	var conv models.Conversation
+	err := ch.Server.Db.Get(ctx, filter, &conv)
+	if models.AllowErrNotFound(err) != nil {
+		ch.Server.Logger.ErrorContext(ctx, "get conversation", "conversation", conversation, "error", err)
+	}
+
+	return ai.RunOptions{Persona: conv.Persona, UserId: userId, Locale: locale, Conversation: conversation}
+}
+
+type PersonaResponse struct {
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// GetPersonas godoc
+//
+//	@Summary		Get personas
+//	@Description	personas the conversation can be started with
+//	@Tags			chat
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	[]PersonaResponse
+//	@Failure		500	{object}	errs.Problem
+//	@Router			/chat/personas [get]
+func (ch *ChatHandler) GetPersonas(c *gin.Context) {
+	personas := ch.Server.AI.Personas()
+
+	response := make([]PersonaResponse, 0, len(personas))
+	for _, p := range personas {
+		response = append(response, PersonaResponse{p.Id, p.Name, p.Description})
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 // WriteChatMessage godoc
 //
 //	@Summary		Writes message
-//	@Description	write message from authorized user to the bot and get response
+//	@Description	write message from authorized user to the bot and get response, a response finished after the client disconnected is pushed to its devices
 //	@Tags			chat
 //	@Accept			json
 //	@Produce		json
 //	@Security		BearerAuth
-//	@Param			rq	body		models.Message.Text	true	"Message text"
-//	@Success		200	{object}	models.Message		"Response from the bot"
-//	@Failure		400	{object}	models.AdvancedErrorResponse
-//	@Failure		500	{object}	models.ErrorResponse
+//	@Param			rq				body		models.MessageFields	true	"Message text"
+//	@Param			Idempotency-Key	header		string					false	"Unique key of the request, retries with the same key get the first response replayed"
+//	@Success		200				{object}	models.Message			"Response from the bot"
+//	@Failure		400				{object}	errs.Problem
+//	@Failure		409				{object}	errs.Problem
+//	@Failure		422				{object}	errs.Problem
+//	@Failure		500				{object}	errs.Problem
 //	@Router			/chat/message [post]
 func (ch *ChatHandler) WriteChatMessage(c *gin.Context) {
-	ctx := c.Request.Context()
+	// the reply is stored and pushed if the client disconnects before the run completes
+	ctx, done := ch.Server.Detach(c.Request.Context())
+	defer done()
 
 	cacheUser, ok := c.Get("user")
 	if !ok {
-		c.AbortWithError(http.StatusUnauthorized, errors.New("not authorized"))
+		c.AbortWithError(http.StatusUnauthorized, errs.ErrUnauthorized)
 		return
 	}
 
-	var input models.Message
+	var input models.MessageFields
 	err := c.ShouldBind(&input)
 	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
+		c.AbortWithError(http.StatusBadRequest, invalidBody(err))
 		return
 	}
 
@@ -138,7 +259,7 @@ func (ch *ChatHandler) WriteChatMessage(c *gin.Context) {
 		}
 
 		if user.Thread == "" {
-			user, err = ch.newUserThread(ctx, cacheUser.(models.User))
+			user, err = ch.newUserThread(ctx, cacheUser.(models.User), "")
 			if err != nil {
 				c.AbortWithError(http.StatusInternalServerError, err)
 				return
@@ -156,9 +277,16 @@ func (ch *ChatHandler) WriteChatMessage(c *gin.Context) {
 		return
 	}
 
-	resp, err := ch.Server.AI.NewMessage(ctx, threadId, input.Text)
+	conversation, err := models.GetConversation(ctx, ch.Server.Db, threadId, cacheUser.(models.User).Id)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	question, answer, err := ch.converse(ctx, conversation, input.Text, middleware.Locale(c))
 	if err != nil && strings.Contains(err.Error(), "error, status code: 404, message: No thread found with id") {
-		user, err := ch.newUserThread(ctx, cacheUser.(models.User))
+		// the lost thread is replaced by a new one with the same persona
+		user, err := ch.newUserThread(ctx, cacheUser.(models.User), conversation.Persona)
 		if err != nil {
 			c.AbortWithError(http.StatusInternalServerError, err)
 			return
@@ -168,7 +296,12 @@ func (ch *ChatHandler) WriteChatMessage(c *gin.Context) {
 			c.AbortWithError(http.StatusInternalServerError, err)
 			return
 		}
-		resp, err = ch.Server.AI.NewMessage(ctx, user.Thread, input.Text)
+		conversation, err = models.GetConversation(ctx, ch.Server.Db, user.Thread, cacheUser.(models.User).Id)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		question, answer, err = ch.converse(ctx, conversation, input.Text, middleware.Locale(c))
 		if err != nil {
 			c.AbortWithError(http.StatusInternalServerError, err)
 			return
@@ -178,15 +311,115 @@ func (ch *ChatHandler) WriteChatMessage(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, models.Message{Text: resp})
+	parentId, err := ch.leaf(ctx, conversation)
+	if err != nil {
+		ch.Server.Logger.ErrorContext(ctx, "last message", "conversation", conversation.Id, "error", err)
+	}
+	stored, _ := ch.storeMessages(ctx, cacheUser.(models.User), conversation, parentId, question, answer)
+	if c.Request.Context().Err() != nil {
+		ch.pushReply(ctx, cacheUser.(models.User), stored[1])
+	}
+
+	// written even to a gone client, so retries with the Idempotency-Key get the reply replayed
+	c.JSON(http.StatusOK, stored[1])
+}
+
+// pushReply notifies the devices of the user about a reply the client disconnected before.
+func (ch *ChatHandler) pushReply(ctx context.Context, user models.User, reply models.Message) {
+	if ch.Server.Push == nil {
+		return
+	}
+
+	text := []rune(reply.Text)
+	if len(text) > replyPushLength {
+		text = append(text[:replyPushLength-1], '…')
+	}
+
+	err := ch.Server.Push.Notify(ctx, models.Notification{
+		UserId:       user.Id,
+		Title:        replyPushTitle,
+		Text:         string(text),
+		Conversation: reply.Conversation,
+		Data:         map[string]string{"message": reply.Id},
+	})
+	if err != nil {
+		ch.Server.Logger.ErrorContext(ctx, "push reply", "message", reply.Id, "error", err)
+	}
+}
+
+// converse sends the user message to the active branch of the conversation and waits for the assistant reply.
+func (ch *ChatHandler) converse(ctx context.Context, conversation models.Conversation, text string, locale string) (models.Message, models.Message, error) {
+	thread := conversation.ActiveThread()
+
+	question, err := ch.Server.AI.AddMessage(ctx, thread, text)
+	if err != nil {
+		return models.Message{}, models.Message{}, err
+	}
+
+	answer, err := ch.Server.AI.Run(ctx, thread, ch.runOptions(ctx, conversation.UserId, conversation.Id, locale))
+	if err != nil {
+		return models.Message{}, models.Message{}, err
+	}
+
+	return question, answer, nil
 }
 
-func (ch *ChatHandler) newUserThread(ctx context.Context, cacheUser models.User) (models.User, error) {
+// storeMessages keeps a local copy of the conversation, chaining messages after parentId, makes the last one
+// the leaf of the active branch and lets the memory catch up. The reply is already produced, so failed writes
+// are only logged. It returns the messages as stored and whether the active branch was saved.
+func (ch *ChatHandler) storeMessages(ctx context.Context, user models.User, conversation models.Conversation, parentId string, messages ...models.Message) ([]models.Message, bool) {
+	stored := make([]models.Message, 0, len(messages))
+	for _, message := range messages {
+		// messages of forked threads belong to the conversation they were forked from
+		message.Conversation = conversation.Id
+		message.ParentId = parentId
+		message.UserId = user.Id
+		message.Language = search.Language(message.Text)
+		stored = append(stored, message)
+
+		if message.Id == "" {
+			continue
+		}
+		parentId = message.Id
+
+		err := ch.Server.Db.Create(ctx, &message)
+		if err != nil {
+			ch.Server.Logger.ErrorContext(ctx, "store message", "message", message.Id, "error", err)
+		}
+	}
+
+	conversation.UserId = user.Id
+	conversation.Leaf = parentId
+	err := models.SaveBranch(ctx, ch.Server.Db, conversation)
+	if err != nil {
+		ch.Server.Logger.ErrorContext(ctx, "save branch", "conversation", conversation.Id, "error", err)
+	}
+
+	ch.Server.AI.Remember(user.Id, conversation.Id)
+
+	return stored, err == nil
+}
+
+// newUserThread starts the main conversation of the user with the persona, the default one if empty or removed.
+func (ch *ChatHandler) newUserThread(ctx context.Context, cacheUser models.User, personaId string) (models.User, error) {
+	persona, err := ch.Server.AI.Persona(personaId)
+	if errors.Is(err, ai.ErrUnknownPersona) {
+		persona, err = ch.Server.AI.Persona("")
+	}
+	if err != nil {
+		return models.User{}, err
+	}
+
 	thread, err := ch.Server.AI.NewThread(ctx)
 	if err != nil {
 		return models.User{}, err
 	}
 
+	err = ch.Server.Db.Create(ctx, &models.Conversation{Id: thread.ID, UserId: cacheUser.Id, Persona: persona.Id})
+	if err != nil {
+		return models.User{}, err
+	}
+
 	var filter models.FilterParams
 	filter.Filter = fmt.Sprintf(`id = '%v'`, cacheUser.Id.String())
 
@@ -208,15 +441,15 @@ func (ch *ChatHandler) newUserThread(ctx context.Context, cacheUser models.User)
 //	@Produce		json
 //	@Security		BearerAuth
 //	@Success		200	{object}	[]models.Message
-//	@Failure		400	{object}	models.AdvancedErrorResponse
-//	@Failure		500	{object}	models.ErrorResponse
-//	@Router			/chat/messages [post]
+//	@Failure		400	{object}	errs.Problem
+//	@Failure		500	{object}	errs.Problem
+//	@Router			/chat/messages [get]
 func (ch *ChatHandler) GetChatMessages(c *gin.Context) {
 	ctx := c.Request.Context()
 
 	cacheUser, ok := c.Get("user")
 	if !ok {
-		c.AbortWithError(http.StatusUnauthorized, errors.New("not authorized"))
+		c.AbortWithError(http.StatusUnauthorized, errs.ErrUnauthorized)
 		return
 	}
 
@@ -260,6 +493,23 @@ func (ch *ChatHandler) GetChatMessages(c *gin.Context) {
 		return
 	}
 
+	conversation, err := models.GetConversation(ctx, ch.Server.Db, threadId, cacheUser.(models.User).Id)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	if conversation.Thread != "" {
+		// the copied messages have other IDs in the forked thread, the branch is read from the local copy
+		branch, err := models.Branch(ctx, ch.Server.Db, conversation)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, branch[max(0, len(branch)-messagesPageSize):])
+		return
+	}
+
 	messages, err := ch.Server.AI.GetMessages(ctx, threadId)
 	if err != nil {
 		c.AbortWithError(http.StatusInternalServerError, err)
@@ -271,6 +521,246 @@ func (ch *ChatHandler) GetChatMessages(c *gin.Context) {
 	c.JSON(http.StatusOK, messages)
 }
 
+// ExportConversation godoc
+//
+//	@Summary		Export conversation
+//	@Description	exports conversation of authorized user as Markdown, print-friendly HTML, JSON or plain text
+//	@Tags			chat
+//	@Accept			json
+//	@Produce		json
+//	@Produce		text/markdown
+//	@Produce		text/html
+//	@Produce		text/plain
+//	@Security		BearerAuth
+//	@Param			id		path		string	true	"Conversation ID"
+//	@Param			format	query		string	false	"Export format"									Enums(md, html, json, txt)
+//	@Param			lang	query		string	false	"Headers language, Accept-Language by default"	Enums(ru, en)
+//	@Param			tz		query		string	false	"IANA time zone of timestamps, UTC by default"
+//	@Param			redact	query		bool	false	"Hide emails, phone numbers and user name"
+//	@Success		200		{object}	transcript.Transcript
+//	@Failure		400		{object}	errs.Problem
+//	@Failure		404		{object}	errs.Problem
+//	@Failure		500		{object}	errs.Problem
+//	@Router			/chat/conversations/{id}/export [get]
+func (ch *ChatHandler) ExportConversation(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	cacheUser, ok := c.Get("user")
+	if !ok {
+		c.AbortWithError(http.StatusUnauthorized, errs.ErrUnauthorized)
+		return
+	}
+
+	format := c.DefaultQuery("format", transcript.FormatMarkdown)
+	contentType, ok := transcript.ContentTypes[format]
+	if !ok {
+		c.AbortWithError(http.StatusBadRequest, models.AdvancedErrorResponse{
+			Key:     "format_field",
+			Code:    http.StatusBadRequest,
+			Message: "Поле 'format' должно быть одним из: md, html, json, txt.",
+		})
+		return
+	}
+
+	location, err := time.LoadLocation(c.DefaultQuery("tz", "UTC"))
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, models.AdvancedErrorResponse{
+			Key:     "tz_field",
+			Code:    http.StatusBadRequest,
+			Message: "Поле 'tz' должно содержать часовой пояс IANA.",
+		})
+		return
+	}
+
+	id := c.Param("id")
+	user, err := ch.conversationOwner(ctx, cacheUser.(models.User), id)
+	if errors.Is(err, errConversationNotFound) {
+		c.AbortWithError(http.StatusNotFound, err)
+		return
+	} else if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	conversation, err := models.GetConversation(ctx, ch.Server.Db, id, user.Id)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	messages, err := ch.branchMessages(ctx, conversation)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	locale := c.Query("lang")
+	if locale == "" {
+		locale = middleware.Locale(c)
+	}
+
+	var buf bytes.Buffer
+	err = transcript.Render(&buf, format, id, messages, transcript.Options{
+		Locale:   locale,
+		Redact:   c.Query("redact") == "true",
+		Personal: []string{user.Name, user.Surname, user.Email, user.Phone},
+		Location: location,
+	})
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	if format != transcript.FormatHTML {
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="conversation-%v.%v"`, id, format))
+	}
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
+// Feedback godoc
+//
+//	@Summary		Rate bot message
+//	@Description	thumbs up or down for the bot reply with optional reasons and comment, repeated rating replaces the previous one
+//	@Tags			chat
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id				path		string					true	"Message ID"
+//	@Param			rq				body		models.FeedbackFields	true	"Feedback"
+//	@Param			Idempotency-Key	header		string					false	"Unique key of the request, retries with the same key get the first response replayed"
+//	@Success		200				{object}	models.Feedback
+//	@Failure		400				{object}	errs.Problem
+//	@Failure		404				{object}	errs.Problem
+//	@Failure		409				{object}	errs.Problem
+//	@Failure		422				{object}	errs.Problem
+//	@Failure		500				{object}	errs.Problem
+//	@Router			/chat/messages/{id}/feedback [post]
+func (ch *ChatHandler) Feedback(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	cacheUser, ok := c.Get("user")
+	if !ok {
+		c.AbortWithError(http.StatusUnauthorized, errs.ErrUnauthorized)
+		return
+	}
+
+	var input models.FeedbackFields
+	err := c.ShouldBind(&input)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	err = input.Validate()
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	userId := cacheUser.(models.User).Id.String()
+
+	var filter models.FilterParams
+	filter.Filter = fmt.Sprintf(`id = '%v' and user_id = '%v' and role = 'assistant'`,
+		strings.ReplaceAll(c.Param("id"), "'", "''"), userId)
+
+	var message models.Message
+	err = ch.Server.Db.Get(ctx, filter, &message)
+	if models.IsErrNotFound(err) {
+		c.AbortWithError(http.StatusNotFound, errMessageNotFound)
+		return
+	} else if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	feedback := models.Feedback{
+		MessageId: message.Id,
+		UserId:    message.UserId,
+		Assistant: message.Assistant,
+		Model:     message.Model,
+		Rating:    input.Score(),
+		Reasons:   input.Reasons,
+		Comment:   input.Comment,
+	}
+
+	// concurrent ratings of the message replace each other instead of failing on the unique index
+	err = ch.Server.Db.UpsertOnConflict(ctx, &feedback, "message_id", "user_id")
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, feedback)
+}
+
+type SearchResponse struct {
+	Hits   []models.SearchHit `json:"hits"`
+	Limit  int                `json:"limit"`
+	Offset int                `json:"offset"`
+}
+
+// Search godoc
+//
+//	@Summary		Search chat history
+//	@Description	full-text search over messages of authorized user in russian and english
+//	@Tags			chat
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			q		query		string	true	"Search query"
+//	@Param			limit	query		int		false	"Page size"
+//	@Param			offset	query		int		false	"Page offset"
+//	@Success		200		{object}	SearchResponse
+//	@Failure		400		{object}	errs.Problem
+//	@Failure		500		{object}	errs.Problem
+//	@Router			/chat/search [get]
+func (ch *ChatHandler) Search(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	cacheUser, ok := c.Get("user")
+	if !ok {
+		c.AbortWithError(http.StatusUnauthorized, errs.ErrUnauthorized)
+		return
+	}
+
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.AbortWithError(http.StatusBadRequest, models.AdvancedErrorResponse{
+			Key:     "q_field",
+			Code:    http.StatusBadRequest,
+			Message: "Поле 'q' должно быть заполнено.",
+		})
+		return
+	}
+
+	var feed models.FeedParams
+	err := c.ShouldBindQuery(&feed)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	if feed.Limit <= 0 {
+		feed.Limit = SearchSizeDefault
+	}
+	if feed.Offset < 0 {
+		feed.Offset = 0
+	}
+
+	sql, args := search.Query(cacheUser.(models.User).Id.String(), query, feed.ValidLimit(), feed.Offset)
+
+	hits := make([]models.SearchHit, 0)
+	err = ch.Server.Db.Raw(ctx, &hits, sql, args)
+	if models.AllowErrNotFound(err) != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	for i := range hits {
+		hits[i].Snippet = search.Highlight(hits[i].Snippet)
+	}
+
+	c.JSON(http.StatusOK, SearchResponse{hits, feed.ValidLimit(), feed.Offset})
+}
+
 type StartAnonChatResponse struct {
 	Id string `json:"id"`
 }
@@ -282,19 +772,34 @@ type StartAnonChatResponse struct {
 //	@Tags			chat
 //	@Accept			json
 //	@Produce		json
-//	@Success		200	{object}	StartAnonChatResponse	"ID of anonymous conversation"
-//	@Failure		400	{object}	models.AdvancedErrorResponse
-//	@Failure		500	{object}	models.ErrorResponse
+//	@Param			rq				body		models.StartChatFields	false	"Persona of the conversation"
+//	@Param			Idempotency-Key	header		string					false	"Unique key of the request, retries with the same key get the first response replayed"
+//	@Success		200				{object}	StartAnonChatResponse	"ID of anonymous conversation"
+//	@Failure		400				{object}	errs.Problem
+//	@Failure		409				{object}	errs.Problem
+//	@Failure		422				{object}	errs.Problem
+//	@Failure		500				{object}	errs.Problem
 //	@Router			/chat/anon/start [post]
 func (ch *ChatHandler) StartAnonChat(c *gin.Context) {
 	ctx := c.Request.Context()
 
+	persona, ok := ch.bindPersona(c)
+	if !ok {
+		return
+	}
+
 	thread, err := ch.Server.AI.NewThread(ctx)
 	if err != nil {
 		c.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
 
+	err = ch.Server.Db.Create(ctx, &models.Conversation{Id: thread.ID, Persona: persona.Id})
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
 	id, err := uuid.NewUUID()
 	if err != nil {
 		c.AbortWithError(http.StatusInternalServerError, err)
@@ -317,21 +822,24 @@ func (ch *ChatHandler) StartAnonChat(c *gin.Context) {
 //	@Tags			chat
 //	@Accept			json
 //	@Produce		json
-//	@Param			id	path		string				true	"ID of anonymous conversation"
-//	@Param			rq	body		models.Message.Text	true	"Message text"
-//	@Success		200	{object}	models.Message		"Response from the bot"
-//	@Failure		400	{object}	models.AdvancedErrorResponse
-//	@Failure		500	{object}	models.ErrorResponse
-//	@Router			/chat/anon/:id/message [post]
+//	@Param			id				path		string					true	"ID of anonymous conversation"
+//	@Param			rq				body		models.MessageFields	true	"Message text"
+//	@Param			Idempotency-Key	header		string					false	"Unique key of the request, retries with the same key get the first response replayed"
+//	@Success		200				{object}	models.Message			"Response from the bot"
+//	@Failure		400				{object}	errs.Problem
+//	@Failure		409				{object}	errs.Problem
+//	@Failure		422				{object}	errs.Problem
+//	@Failure		500				{object}	errs.Problem
+//	@Router			/chat/anon/{id}/message [post]
 func (ch *ChatHandler) WriteAnonChatMessage(c *gin.Context) {
 	ctx := c.Request.Context()
 
 	id := c.Param("id")
 
-	var input models.Message
+	var input models.MessageFields
 	err := c.ShouldBind(&input)
 	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
+		c.AbortWithError(http.StatusBadRequest, invalidBody(err))
 		return
 	}
 
@@ -351,7 +859,7 @@ func (ch *ChatHandler) WriteAnonChatMessage(c *gin.Context) {
 		return
 	}
 
-	resp, err := ch.Server.AI.NewMessage(ctx, thread, input.Text)
+	resp, err := ch.Server.AI.NewMessage(ctx, thread, input.Text, ch.runOptions(ctx, uuid.Nil, thread, middleware.Locale(c)))
 	if err != nil {
 		c.AbortWithError(http.StatusInternalServerError, err)
 		return
@@ -369,9 +877,9 @@ func (ch *ChatHandler) WriteAnonChatMessage(c *gin.Context) {
 //	@Produce		json
 //	@Param			id	path		string	true	"ID of anonymous conversation"
 //	@Success		200	{object}	[]models.Message
-//	@Failure		400	{object}	models.AdvancedErrorResponse
-//	@Failure		500	{object}	models.ErrorResponse
-//	@Router			/chat/anon/:id/messages [get]
+//	@Failure		400	{object}	errs.Problem
+//	@Failure		500	{object}	errs.Problem
+//	@Router			/chat/anon/{id}/messages [get]
 func (ch *ChatHandler) GetAnonChatMessages(c *gin.Context) {
 	ctx := c.Request.Context()
 
@@ -1,5 +1,13 @@
 package handler
 
+import (
+	"chatgpt/errs"
+	"chatgpt/models"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
 type Response struct {
 	Result string `json:"result"`
 }
@@ -7,3 +15,30 @@ type Response struct {
 type ResponseMsg struct {
 	Message string `json:"message"`
 }
+
+// bindFeed reads paging from the query, the page size falls back to size.
+func bindFeed(c *gin.Context, size int) (models.FeedParams, error) {
+	var feed models.FeedParams
+	err := c.ShouldBindQuery(&feed)
+	if err != nil {
+		return feed, err
+	}
+	if feed.Limit <= 0 {
+		feed.Limit = size
+	}
+	feed.Limit = feed.ValidLimit()
+	if feed.Offset < 0 {
+		feed.Offset = 0
+	}
+	return feed, nil
+}
+
+// invalidBody wraps the error of a request body that does not bind, the cause is only logged.
+// Bodies over the limit of BodyLimit keep failing with 413.
+func invalidBody(err error) *errs.Error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return errs.From(err, http.StatusRequestEntityTooLarge)
+	}
+	return errs.Wrap(err, errs.CodeBadRequest, string(errs.CodeBadRequest), "invalid request body")
+}
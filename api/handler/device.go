@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"chatgpt/api/middleware"
+	"chatgpt/errs"
+	"chatgpt/models"
+	"chatgpt/server"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type DeviceHandler struct {
+	Server *server.Server
+}
+
+func NewDeviceHandler(server *server.Server) *DeviceHandler {
+	return &DeviceHandler{server}
+}
+
+func (d *DeviceHandler) Init() {
+	d.Server.Handle(func(r *gin.RouterGroup) {
+		devices := r.Group("/profile/devices", middleware.Authenticate(d.Server.Cache))
+		devices.POST("", d.Register)
+		devices.DELETE("", d.Unregister)
+	})
+}
+
+// Register godoc
+//
+//	@Summary		Register device
+//	@Description	Register FCM or APNs token of the app for push notifications. A token registered by another user is moved to the current one.
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			rq	body		models.DeviceFields	true	"Device"
+//	@Success		200	{object}	models.Device
+//	@Failure		400	{object}	errs.Problem
+//	@Failure		500	{object}	errs.Problem
+//	@Router			/profile/devices [post]
+func (d *DeviceHandler) Register(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.AbortWithError(http.StatusUnauthorized, errs.ErrUnauthorized)
+		return
+	}
+
+	var input models.DeviceFields
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	err = input.Validate()
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	var filter models.FilterParams
+	filter.Filter = fmt.Sprintf(`token = '%v'`, strings.ReplaceAll(input.Token, "'", "''"))
+
+	var device models.Device
+	err = d.Server.Db.Get(ctx, filter, &device)
+	if models.AllowErrNotFound(err) != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	device.UserId = user.(models.User).Id
+	device.Token = input.Token
+	device.Provider = input.Provider
+	device.Platform = input.Platform
+	device.Locale = input.Locale
+	device.UpdatedAt = time.Now().UTC()
+
+	if models.IsErrNotFound(err) {
+		device.CreatedAt = device.UpdatedAt
+		err = d.Server.Db.Create(ctx, &device)
+	} else {
+		err = d.Server.Db.Update(ctx, filter, &device)
+	}
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, device)
+}
+
+// Unregister godoc
+//
+//	@Summary		Unregister device
+//	@Description	Stop push notifications to the device token, e.g. on logout
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			rq	body		models.DeviceTokenFields	true	"Device token"
+//	@Success		200	{object}	Response
+//	@Failure		400	{object}	errs.Problem
+//	@Failure		404	{object}	errs.Problem
+//	@Failure		500	{object}	errs.Problem
+//	@Router			/profile/devices [delete]
+func (d *DeviceHandler) Unregister(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.AbortWithError(http.StatusUnauthorized, errs.ErrUnauthorized)
+		return
+	}
+
+	var input models.DeviceTokenFields
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	if input.Token == "" {
+		c.AbortWithError(http.StatusBadRequest, models.AdvancedErrorResponse{
+			Key:     "token_field",
+			Code:    http.StatusBadRequest,
+			Message: "Поле 'token' должно быть заполнено.",
+		})
+		return
+	}
+
+	var filter models.FilterParams
+	filter.Filter = fmt.Sprintf(`token = '%v' and user_id = '%v'`,
+		strings.ReplaceAll(input.Token, "'", "''"), user.(models.User).Id.String())
+
+	err = d.Server.Db.Delete(ctx, filter, &models.Device{})
+	if models.IsErrNotFound(err) {
+		c.AbortWithError(http.StatusNotFound, errs.New(errs.CodeNotFound, "device_not_found", "device not found"))
+		return
+	} else if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{"device unregistered"})
+}
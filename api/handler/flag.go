@@ -0,0 +1,256 @@
+package handler
+
+import (
+	"chatgpt/api/middleware"
+	"chatgpt/errs"
+	"chatgpt/models"
+	"chatgpt/server"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	errFlagNotFound = errs.New(errs.CodeNotFound, "flag_not_found", "feature flag not found")
+	errFlagExists   = errs.New(errs.CodeConflict, "flag_exists", "feature flag already exists")
+)
+
+type FlagHandler struct {
+	Server *server.Server
+}
+
+func NewFlagHandler(server *server.Server) *FlagHandler {
+	return &FlagHandler{server}
+}
+
+func (f *FlagHandler) Init() {
+	f.Server.Handle(func(r *gin.RouterGroup) {
+		flags := r.Group("/admin/flags", middleware.Authenticate(f.Server.Cache), middleware.RequireRole(RoleAdmin))
+		flags.GET("", f.List)
+		flags.POST("", f.Create)
+		flags.GET("/:key", f.Get)
+		flags.PUT("/:key", f.Update)
+		flags.DELETE("/:key", f.Delete)
+	})
+}
+
+func flagFilter(key string) models.FilterParams {
+	var filter models.FilterParams
+	filter.Filter = fmt.Sprintf(`key = '%v'`, strings.ReplaceAll(key, "'", "''"))
+	return filter
+}
+
+func (f *FlagHandler) flag(input models.FeatureFlagFields, createdAt time.Time) models.FeatureFlag {
+	return models.FeatureFlag{
+		Key:         input.Key,
+		Description: input.Description,
+		Enabled:     input.Enabled,
+		Percentage:  input.Percentage,
+		Roles:       input.Roles,
+		Users:       input.Users,
+		CreatedAt:   createdAt,
+		UpdatedAt:   time.Now().UTC(),
+	}
+}
+
+// List godoc
+//
+//	@Summary		Feature flags
+//	@Description	all feature flags sorted by key
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	[]models.FeatureFlag
+//	@Failure		403	{object}	errs.Problem
+//	@Failure		500	{object}	errs.Problem
+//	@Router			/admin/flags [get]
+func (f *FlagHandler) List(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var filter models.FilterParams
+	filter.Orderings = "key"
+
+	flags := make([]models.FeatureFlag, 0)
+	err := f.Server.Db.Get(ctx, filter, &flags)
+	if models.AllowErrNotFound(err) != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, flags)
+}
+
+// Create godoc
+//
+//	@Summary		Create feature flag
+//	@Description	the flag is on for the listed users and roles and for the percentage of other users and anonymous sessions, while enabled
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			rq	body		models.FeatureFlagFields	true	"Feature flag"
+//	@Success		201	{object}	models.FeatureFlag
+//	@Failure		400	{object}	errs.Problem
+//	@Failure		403	{object}	errs.Problem
+//	@Failure		409	{object}	errs.Problem
+//	@Failure		500	{object}	errs.Problem
+//	@Router			/admin/flags [post]
+func (f *FlagHandler) Create(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var input models.FeatureFlagFields
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	err = input.Validate()
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	var existing models.FeatureFlag
+	err = f.Server.Db.Get(ctx, flagFilter(input.Key), &existing)
+	if err == nil {
+		c.AbortWithError(http.StatusConflict, errFlagExists)
+		return
+	} else if !models.IsErrNotFound(err) {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	flag := f.flag(input, time.Now().UTC())
+	err = f.Server.Db.Create(ctx, &flag)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	f.Server.Flags.Invalidate()
+
+	c.JSON(http.StatusCreated, flag)
+}
+
+// Get godoc
+//
+//	@Summary		Get feature flag
+//	@Description	get feature flag by key
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			key	path		string	true	"Flag key"
+//	@Success		200	{object}	models.FeatureFlag
+//	@Failure		403	{object}	errs.Problem
+//	@Failure		404	{object}	errs.Problem
+//	@Failure		500	{object}	errs.Problem
+//	@Router			/admin/flags/{key} [get]
+func (f *FlagHandler) Get(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var flag models.FeatureFlag
+	err := f.Server.Db.Get(ctx, flagFilter(c.Param("key")), &flag)
+	if models.IsErrNotFound(err) {
+		c.AbortWithError(http.StatusNotFound, errFlagNotFound)
+		return
+	} else if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, flag)
+}
+
+// Update godoc
+//
+//	@Summary		Replace feature flag
+//	@Description	replaces the flag, other replicas apply the change within 30 seconds
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			key	path		string						true	"Flag key"
+//	@Param			rq	body		models.FeatureFlagFields	true	"Feature flag"
+//	@Success		200	{object}	models.FeatureFlag
+//	@Failure		400	{object}	errs.Problem
+//	@Failure		403	{object}	errs.Problem
+//	@Failure		404	{object}	errs.Problem
+//	@Failure		500	{object}	errs.Problem
+//	@Router			/admin/flags/{key} [put]
+func (f *FlagHandler) Update(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	filter := flagFilter(c.Param("key"))
+
+	var existing models.FeatureFlag
+	err := f.Server.Db.Get(ctx, filter, &existing)
+	if models.IsErrNotFound(err) {
+		c.AbortWithError(http.StatusNotFound, errFlagNotFound)
+		return
+	} else if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	var input models.FeatureFlagFields
+	err = c.ShouldBindJSON(&input)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	input.Key = existing.Key
+
+	err = input.Validate()
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	// Update skips false and zero fields, all columns are replaced to allow turning the flag off.
+	flag := f.flag(input, existing.CreatedAt)
+	err = f.Server.Db.Replace(ctx, filter, &flag)
+	if models.IsErrNotFound(err) {
+		c.AbortWithError(http.StatusNotFound, errFlagNotFound)
+		return
+	} else if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	f.Server.Flags.Invalidate()
+
+	c.JSON(http.StatusOK, flag)
+}
+
+// Delete godoc
+//
+//	@Summary		Delete feature flag
+//	@Description	deleted flags are off for everybody
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			key	path		string	true	"Flag key"
+//	@Success		200	{object}	Response
+//	@Failure		403	{object}	errs.Problem
+//	@Failure		404	{object}	errs.Problem
+//	@Failure		500	{object}	errs.Problem
+//	@Router			/admin/flags/{key} [delete]
+func (f *FlagHandler) Delete(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	err := f.Server.Db.Delete(ctx, flagFilter(c.Param("key")), &models.FeatureFlag{})
+	if models.IsErrNotFound(err) {
+		c.AbortWithError(http.StatusNotFound, errFlagNotFound)
+		return
+	} else if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	f.Server.Flags.Invalidate()
+
+	c.JSON(http.StatusOK, Response{"feature flag deleted"})
+}
@@ -5,16 +5,28 @@ import (
 )
 
 type Handler struct {
-	AuthHandler *AuthHandler
-	UserHandler *UserHandler
-	ChatHandler *ChatHandler
+	AuthHandler     *AuthHandler
+	UserHandler     *UserHandler
+	ChatHandler     *ChatHandler
+	AdminHandler    *AdminHandler
+	MoodHandler     *MoodHandler
+	JournalHandler  *JournalHandler
+	ReminderHandler *ReminderHandler
+	DeviceHandler   *DeviceHandler
+	FlagHandler     *FlagHandler
 }
 
 func NewHandler(server *server.Server) *Handler {
 	return &Handler{
-		AuthHandler: NewAuthHandler(server),
-		UserHandler: NewUserHandler(server),
-		ChatHandler: NewChatHandler(server),
+		AuthHandler:     NewAuthHandler(server),
+		UserHandler:     NewUserHandler(server),
+		ChatHandler:     NewChatHandler(server),
+		AdminHandler:    NewAdminHandler(server),
+		MoodHandler:     NewMoodHandler(server),
+		JournalHandler:  NewJournalHandler(server),
+		ReminderHandler: NewReminderHandler(server),
+		DeviceHandler:   NewDeviceHandler(server),
+		FlagHandler:     NewFlagHandler(server),
 	}
 }
 
@@ -22,4 +34,10 @@ func (h *Handler) InitRoutes() {
 	h.AuthHandler.Init()
 	h.UserHandler.Init()
 	h.ChatHandler.Init()
+	h.AdminHandler.Init()
+	h.MoodHandler.Init()
+	h.JournalHandler.Init()
+	h.ReminderHandler.Init()
+	h.DeviceHandler.Init()
+	h.FlagHandler.Init()
 }
@@ -0,0 +1,301 @@
+package handler
+
+import (
+	"chatgpt/api/middleware"
+	"chatgpt/errs"
+	"chatgpt/models"
+	"chatgpt/server"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+const JournalPageSize = 20
+
+var errJournalNotFound = errs.New(errs.CodeNotFound, "journal_not_found", "journal entry not found")
+
+type JournalHandler struct {
+	Server *server.Server
+}
+
+func NewJournalHandler(server *server.Server) *JournalHandler {
+	return &JournalHandler{server}
+}
+
+func (j *JournalHandler) Init() {
+	j.Server.Handle(func(r *gin.RouterGroup) {
+		journal := r.Group("/profile/journal", middleware.Authenticate(j.Server.Cache))
+		journal.POST("", j.Create)
+		journal.GET("", j.List)
+		journal.GET("/:id", j.Get)
+		journal.PATCH("/:id", j.Update)
+		journal.DELETE("/:id", j.Delete)
+	})
+}
+
+type JournalResponse struct {
+	Entries []models.JournalEntry `json:"entries"`
+	Limit   int                   `json:"limit"`
+	Offset  int                   `json:"offset"`
+}
+
+// Create godoc
+//
+//	@Summary		Write journal entry
+//	@Description	Write journal entry with optional title
+//	@Tags			journal
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			rq	body		models.JournalFields	true	"Journal entry"
+//	@Success		201	{object}	models.JournalEntry
+//	@Failure		400	{object}	errs.Problem
+//	@Failure		500	{object}	errs.Problem
+//	@Router			/profile/journal [post]
+func (j *JournalHandler) Create(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.AbortWithError(http.StatusUnauthorized, errs.ErrUnauthorized)
+		return
+	}
+
+	var input models.JournalFields
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	err = input.Validate()
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	now := time.Now().UTC()
+	entry := models.JournalEntry{
+		UserId:    user.(models.User).Id,
+		Title:     input.Title,
+		Text:      input.Text,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	err = j.Server.Db.Create(ctx, &entry)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, entry)
+}
+
+// List godoc
+//
+//	@Summary		Journal
+//	@Description	Journal entries of authorized user, newest first
+//	@Tags			journal
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			from	query		string	false	"First day, YYYY-MM-DD"
+//	@Param			to		query		string	false	"Last day, YYYY-MM-DD"
+//	@Param			limit	query		int		false	"Page size"
+//	@Param			offset	query		int		false	"Page offset"
+//	@Success		200		{object}	JournalResponse
+//	@Failure		400		{object}	errs.Problem
+//	@Failure		500		{object}	errs.Problem
+//	@Router			/profile/journal [get]
+func (j *JournalHandler) List(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.AbortWithError(http.StatusUnauthorized, errs.ErrUnauthorized)
+		return
+	}
+
+	feed, err := bindFeed(c, JournalPageSize)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	feed.Orderings = "created_at desc"
+
+	days, err := dayRange(c)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	filter := models.FilterParams{
+		Filter:     fmt.Sprintf(`user_id = '%v'%v`, user.(models.User).Id.String(), days),
+		FeedParams: feed,
+	}
+
+	entries := make([]models.JournalEntry, 0)
+	err = j.Server.Db.GetView(ctx, "journal_entries", filter, &entries)
+	if models.AllowErrNotFound(err) != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, JournalResponse{entries, feed.Limit, feed.Offset})
+}
+
+// Get godoc
+//
+//	@Summary		Get journal entry
+//	@Description	Get journal entry of authorized user
+//	@Tags			journal
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		string	true	"Journal entry ID"
+//	@Success		200	{object}	models.JournalEntry
+//	@Failure		404	{object}	errs.Problem
+//	@Failure		500	{object}	errs.Problem
+//	@Router			/profile/journal/{id} [get]
+func (j *JournalHandler) Get(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.AbortWithError(http.StatusUnauthorized, errs.ErrUnauthorized)
+		return
+	}
+
+	filter, ok := ownedFilter(c, user.(models.User))
+	if !ok {
+		c.AbortWithError(http.StatusNotFound, errJournalNotFound)
+		return
+	}
+
+	var entry models.JournalEntry
+	err := j.Server.Db.Get(ctx, filter, &entry)
+	if models.IsErrNotFound(err) {
+		c.AbortWithError(http.StatusNotFound, errJournalNotFound)
+		return
+	} else if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+// Update godoc
+//
+//	@Summary		Update journal entry
+//	@Description	Update title or text of journal entry, omitted fields are kept
+//	@Tags			journal
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		string					true	"Journal entry ID"
+//	@Param			rq	body		models.JournalFields	true	"Journal entry"
+//	@Success		200	{object}	models.JournalEntry
+//	@Failure		400	{object}	errs.Problem
+//	@Failure		404	{object}	errs.Problem
+//	@Failure		500	{object}	errs.Problem
+//	@Router			/profile/journal/{id} [patch]
+func (j *JournalHandler) Update(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.AbortWithError(http.StatusUnauthorized, errs.ErrUnauthorized)
+		return
+	}
+
+	filter, ok := ownedFilter(c, user.(models.User))
+	if !ok {
+		c.AbortWithError(http.StatusNotFound, errJournalNotFound)
+		return
+	}
+
+	var entry models.JournalEntry
+	err := j.Server.Db.Get(ctx, filter, &entry)
+	if models.IsErrNotFound(err) {
+		c.AbortWithError(http.StatusNotFound, errJournalNotFound)
+		return
+	} else if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	input := models.JournalFields{
+		Title: entry.Title,
+		Text:  entry.Text,
+	}
+	err = c.ShouldBindJSON(&input)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	err = input.Validate()
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	entry.Title = input.Title
+	entry.Text = input.Text
+	entry.UpdatedAt = time.Now().UTC()
+
+	// Update skips empty fields, all columns are replaced to allow clearing them.
+	err = j.Server.Db.Replace(ctx, filter, &entry)
+	if models.IsErrNotFound(err) {
+		c.AbortWithError(http.StatusNotFound, errJournalNotFound)
+		return
+	} else if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+// Delete godoc
+//
+//	@Summary		Delete journal entry
+//	@Description	Delete journal entry of authorized user
+//	@Tags			journal
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		string	true	"Journal entry ID"
+//	@Success		200	{object}	Response
+//	@Failure		404	{object}	errs.Problem
+//	@Failure		500	{object}	errs.Problem
+//	@Router			/profile/journal/{id} [delete]
+func (j *JournalHandler) Delete(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.AbortWithError(http.StatusUnauthorized, errs.ErrUnauthorized)
+		return
+	}
+
+	filter, ok := ownedFilter(c, user.(models.User))
+	if !ok {
+		c.AbortWithError(http.StatusNotFound, errJournalNotFound)
+		return
+	}
+
+	err := j.Server.Db.Delete(ctx, filter, &models.JournalEntry{})
+	if models.IsErrNotFound(err) {
+		c.AbortWithError(http.StatusNotFound, errJournalNotFound)
+		return
+	} else if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{"journal entry deleted"})
+}
@@ -0,0 +1,440 @@
+package handler
+
+import (
+	"chatgpt/api/middleware"
+	"chatgpt/errs"
+	"chatgpt/models"
+	"chatgpt/server"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	MoodPageSize = 30
+
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+)
+
+var errMoodNotFound = errs.New(errs.CodeNotFound, "mood_not_found", "mood entry not found")
+
+type MoodHandler struct {
+	Server *server.Server
+}
+
+func NewMoodHandler(server *server.Server) *MoodHandler {
+	return &MoodHandler{server}
+}
+
+func (m *MoodHandler) Init() {
+	m.Server.Handle(func(r *gin.RouterGroup) {
+		moods := r.Group("/profile/moods", middleware.Authenticate(m.Server.Cache))
+		moods.POST("", m.Create)
+		moods.GET("", m.List)
+		moods.GET("/stats", m.Stats)
+		moods.GET("/:id", m.Get)
+		moods.PATCH("/:id", m.Update)
+		moods.DELETE("/:id", m.Delete)
+	})
+}
+
+type MoodsResponse struct {
+	Moods  []models.MoodEntry `json:"moods"`
+	Limit  int                `json:"limit"`
+	Offset int                `json:"offset"`
+}
+
+// Create godoc
+//
+//	@Summary		Log mood
+//	@Description	Log mood score from 1 to 10 with emotions and a note, time defaults to now
+//	@Tags			mood
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			rq	body		models.MoodFields	true	"Mood"
+//	@Success		201	{object}	models.MoodEntry
+//	@Failure		400	{object}	errs.Problem
+//	@Failure		500	{object}	errs.Problem
+//	@Router			/profile/moods [post]
+func (m *MoodHandler) Create(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.AbortWithError(http.StatusUnauthorized, errs.ErrUnauthorized)
+		return
+	}
+
+	var input models.MoodFields
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	err = input.Validate()
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	entry := models.MoodEntry{
+		UserId:    user.(models.User).Id,
+		Score:     input.Score,
+		Emotions:  input.Emotions,
+		Note:      input.Note,
+		Source:    models.SourceUser,
+		CreatedAt: time.Now().UTC(),
+	}
+	if input.Time != nil {
+		entry.CreatedAt = input.Time.UTC()
+	}
+
+	err = m.Server.Db.Create(ctx, &entry)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, entry)
+}
+
+// List godoc
+//
+//	@Summary		Mood history
+//	@Description	Mood entries of authorized user, newest first
+//	@Tags			mood
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			from	query		string	false	"First day, YYYY-MM-DD"
+//	@Param			to		query		string	false	"Last day, YYYY-MM-DD"
+//	@Param			limit	query		int		false	"Page size"
+//	@Param			offset	query		int		false	"Page offset"
+//	@Success		200		{object}	MoodsResponse
+//	@Failure		400		{object}	errs.Problem
+//	@Failure		500		{object}	errs.Problem
+//	@Router			/profile/moods [get]
+func (m *MoodHandler) List(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.AbortWithError(http.StatusUnauthorized, errs.ErrUnauthorized)
+		return
+	}
+
+	feed, err := bindFeed(c, MoodPageSize)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	feed.Orderings = "created_at desc"
+
+	period, err := dayRange(c)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	filter := models.FilterParams{
+		Filter:     fmt.Sprintf(`user_id = '%v'%v`, user.(models.User).Id.String(), period),
+		FeedParams: feed,
+	}
+
+	moods := make([]models.MoodEntry, 0)
+	err = m.Server.Db.GetView(ctx, "mood_entries", filter, &moods)
+	if models.AllowErrNotFound(err) != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, MoodsResponse{moods, feed.Limit, feed.Offset})
+}
+
+// Get godoc
+//
+//	@Summary		Get mood entry
+//	@Description	Get mood entry of authorized user
+//	@Tags			mood
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		string	true	"Mood entry ID"
+//	@Success		200	{object}	models.MoodEntry
+//	@Failure		404	{object}	errs.Problem
+//	@Failure		500	{object}	errs.Problem
+//	@Router			/profile/moods/{id} [get]
+func (m *MoodHandler) Get(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.AbortWithError(http.StatusUnauthorized, errs.ErrUnauthorized)
+		return
+	}
+
+	filter, ok := ownedFilter(c, user.(models.User))
+	if !ok {
+		c.AbortWithError(http.StatusNotFound, errMoodNotFound)
+		return
+	}
+
+	var entry models.MoodEntry
+	err := m.Server.Db.Get(ctx, filter, &entry)
+	if models.IsErrNotFound(err) {
+		c.AbortWithError(http.StatusNotFound, errMoodNotFound)
+		return
+	} else if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+// Update godoc
+//
+//	@Summary		Update mood entry
+//	@Description	Update score, emotions, note or time of mood entry, omitted fields are kept
+//	@Tags			mood
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		string				true	"Mood entry ID"
+//	@Param			rq	body		models.MoodFields	true	"Mood"
+//	@Success		200	{object}	models.MoodEntry
+//	@Failure		400	{object}	errs.Problem
+//	@Failure		404	{object}	errs.Problem
+//	@Failure		500	{object}	errs.Problem
+//	@Router			/profile/moods/{id} [patch]
+func (m *MoodHandler) Update(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.AbortWithError(http.StatusUnauthorized, errs.ErrUnauthorized)
+		return
+	}
+
+	filter, ok := ownedFilter(c, user.(models.User))
+	if !ok {
+		c.AbortWithError(http.StatusNotFound, errMoodNotFound)
+		return
+	}
+
+	var entry models.MoodEntry
+	err := m.Server.Db.Get(ctx, filter, &entry)
+	if models.IsErrNotFound(err) {
+		c.AbortWithError(http.StatusNotFound, errMoodNotFound)
+		return
+	} else if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	input := models.MoodFields{
+		Score:    entry.Score,
+		Emotions: entry.Emotions,
+		Note:     entry.Note,
+	}
+	err = c.ShouldBindJSON(&input)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	err = input.Validate()
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	entry.Score = input.Score
+	entry.Emotions = input.Emotions
+	entry.Note = input.Note
+	if input.Time != nil {
+		entry.CreatedAt = input.Time.UTC()
+	}
+
+	// Update skips empty fields, all columns are replaced to allow clearing them.
+	err = m.Server.Db.Replace(ctx, filter, &entry)
+	if models.IsErrNotFound(err) {
+		c.AbortWithError(http.StatusNotFound, errMoodNotFound)
+		return
+	} else if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+// Delete godoc
+//
+//	@Summary		Delete mood entry
+//	@Description	Delete mood entry of authorized user
+//	@Tags			mood
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		string	true	"Mood entry ID"
+//	@Success		200	{object}	Response
+//	@Failure		404	{object}	errs.Problem
+//	@Failure		500	{object}	errs.Problem
+//	@Router			/profile/moods/{id} [delete]
+func (m *MoodHandler) Delete(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.AbortWithError(http.StatusUnauthorized, errs.ErrUnauthorized)
+		return
+	}
+
+	filter, ok := ownedFilter(c, user.(models.User))
+	if !ok {
+		c.AbortWithError(http.StatusNotFound, errMoodNotFound)
+		return
+	}
+
+	err := m.Server.Db.Delete(ctx, filter, &models.MoodEntry{})
+	if models.IsErrNotFound(err) {
+		c.AbortWithError(http.StatusNotFound, errMoodNotFound)
+		return
+	} else if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{"mood entry deleted"})
+}
+
+// Stats godoc
+//
+//	@Summary		Mood aggregates
+//	@Description	Count, average, min and max mood score per week or month in the user time zone
+//	@Tags			mood
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			period	query		string	false	"Aggregation period, week by default"	Enums(week, month)
+//	@Param			tz		query		string	false	"IANA time zone of the periods and days, UTC by default"
+//	@Param			from	query		string	false	"First day, YYYY-MM-DD"
+//	@Param			to		query		string	false	"Last day, YYYY-MM-DD"
+//	@Success		200		{object}	[]models.MoodAggregate
+//	@Failure		400		{object}	errs.Problem
+//	@Failure		500		{object}	errs.Problem
+//	@Router			/profile/moods/stats [get]
+func (m *MoodHandler) Stats(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.AbortWithError(http.StatusUnauthorized, errs.ErrUnauthorized)
+		return
+	}
+
+	period := c.DefaultQuery("period", PeriodWeek)
+	if period != PeriodWeek && period != PeriodMonth {
+		c.AbortWithError(http.StatusBadRequest, models.AdvancedErrorResponse{
+			Key:     "period_field",
+			Code:    http.StatusBadRequest,
+			Message: "Поле 'period' должно быть 'week' или 'month'.",
+		})
+		return
+	}
+
+	// Local is the zone of the server, Postgres does not know it
+	location, err := time.LoadLocation(c.DefaultQuery("tz", "UTC"))
+	if err != nil || location == time.Local {
+		c.AbortWithError(http.StatusBadRequest, models.AdvancedErrorResponse{
+			Key:     "tz_field",
+			Code:    http.StatusBadRequest,
+			Message: "Поле 'tz' должно быть часовым поясом IANA.",
+		})
+		return
+	}
+
+	days, err := dayRangeIn(c, location)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	filter := models.FilterParams{
+		Filter: fmt.Sprintf(`user_id = '%v'%v`, user.(models.User).Id.String(), days),
+		Select: fmt.Sprintf(`date_trunc('%v', created_at at time zone '%v') as period,
+			count(*) as count,
+			avg(score)::float as avg_score,
+			min(score) as min_score,
+			max(score) as max_score`, period, strings.ReplaceAll(location.String(), "'", "''")),
+		Group: "period",
+		FeedParams: models.FeedParams{
+			Orderings: "period desc",
+		},
+	}
+
+	aggregates := make([]models.MoodAggregate, 0)
+	err = m.Server.Db.Select(ctx, "mood_entries", filter, &aggregates)
+	if models.AllowErrNotFound(err) != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, aggregates)
+}
+
+// ownedFilter matches the :id row of the user, false if id is not a valid UUID.
+func ownedFilter(c *gin.Context, user models.User) (models.FilterParams, bool) {
+	var filter models.FilterParams
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return filter, false
+	}
+
+	filter.Filter = fmt.Sprintf(`id = '%v' and user_id = '%v'`, id.String(), user.Id.String())
+	return filter, true
+}
+
+// dayRange returns the created_at condition for optional from and to days in UTC.
+func dayRange(c *gin.Context) (string, error) {
+	return dayRangeIn(c, time.UTC)
+}
+
+// dayRangeIn returns the created_at condition for optional from and to days starting at midnight in the location.
+func dayRangeIn(c *gin.Context, location *time.Location) (string, error) {
+	var condition string
+
+	if value := c.Query("from"); value != "" {
+		from, err := time.ParseInLocation(time.DateOnly, value, location)
+		if err != nil {
+			return "", models.AdvancedErrorResponse{
+				Key:     "from_field",
+				Code:    http.StatusBadRequest,
+				Message: "Поле 'from' должно быть датой в формате YYYY-MM-DD.",
+			}
+		}
+		condition += fmt.Sprintf(` and created_at >= '%v'`, from.Format(time.RFC3339))
+	}
+
+	if value := c.Query("to"); value != "" {
+		to, err := time.ParseInLocation(time.DateOnly, value, location)
+		if err != nil {
+			return "", models.AdvancedErrorResponse{
+				Key:     "to_field",
+				Code:    http.StatusBadRequest,
+				Message: "Поле 'to' должно быть датой в формате YYYY-MM-DD.",
+			}
+		}
+		condition += fmt.Sprintf(` and created_at < '%v'`, to.AddDate(0, 0, 1).Format(time.RFC3339))
+	}
+
+	return condition, nil
+}
@@ -0,0 +1,272 @@
+package handler
+
+import (
+	"chatgpt/api/middleware"
+	"chatgpt/errs"
+	"chatgpt/models"
+	"chatgpt/server"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var errReminderNotFound = errs.New(errs.CodeNotFound, "reminder_not_found", "reminder not found")
+
+type ReminderHandler struct {
+	Server *server.Server
+}
+
+func NewReminderHandler(server *server.Server) *ReminderHandler {
+	return &ReminderHandler{server}
+}
+
+func (r *ReminderHandler) Init() {
+	r.Server.Handle(func(rg *gin.RouterGroup) {
+		reminders := rg.Group("/profile/reminders", middleware.Authenticate(r.Server.Cache))
+		reminders.POST("", r.Create)
+		reminders.GET("", r.List)
+		reminders.PATCH("/:id", r.Update)
+		reminders.DELETE("/:id", r.Delete)
+	})
+}
+
+// Create godoc
+//
+//	@Summary		Schedule check-in
+//	@Description	Schedule daily or weekly check-in at the local time of the user time zone. With startChat the bot writes first in the conversation.
+//	@Tags			reminders
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			rq	body		models.ReminderFields	true	"Reminder"
+//	@Success		201	{object}	models.Reminder
+//	@Failure		400	{object}	errs.Problem
+//	@Failure		500	{object}	errs.Problem
+//	@Router			/profile/reminders [post]
+func (r *ReminderHandler) Create(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.AbortWithError(http.StatusUnauthorized, errs.ErrUnauthorized)
+		return
+	}
+
+	var input models.ReminderFields
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	reminder, err := r.reminder(ctx, user.(models.User), models.Reminder{UserId: user.(models.User).Id}, input)
+	if err != nil {
+		c.AbortWithError(reminderErrorStatus(err), err)
+		return
+	}
+
+	err = r.Server.Db.Create(ctx, &reminder)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, reminder)
+}
+
+// List godoc
+//
+//	@Summary		Get check-ins
+//	@Description	Scheduled check-ins of authorized user, the nearest first
+//	@Tags			reminders
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	[]models.Reminder
+//	@Failure		500	{object}	errs.Problem
+//	@Router			/profile/reminders [get]
+func (r *ReminderHandler) List(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.AbortWithError(http.StatusUnauthorized, errs.ErrUnauthorized)
+		return
+	}
+
+	var filter models.FilterParams
+	filter.Filter = fmt.Sprintf(`user_id = '%v'`, user.(models.User).Id.String())
+	filter.Orderings = "disabled, next_run_at"
+
+	reminders := make([]models.Reminder, 0)
+	err := r.Server.Db.Get(ctx, filter, &reminders)
+	if models.AllowErrNotFound(err) != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reminders)
+}
+
+// Update godoc
+//
+//	@Summary		Update check-in
+//	@Description	Update check-in of authorized user, omitted fields are kept
+//	@Tags			reminders
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		string					true	"Reminder ID"
+//	@Param			rq	body		models.ReminderFields	true	"Reminder"
+//	@Success		200	{object}	models.Reminder
+//	@Failure		400	{object}	errs.Problem
+//	@Failure		404	{object}	errs.Problem
+//	@Failure		500	{object}	errs.Problem
+//	@Router			/profile/reminders/{id} [patch]
+func (r *ReminderHandler) Update(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.AbortWithError(http.StatusUnauthorized, errs.ErrUnauthorized)
+		return
+	}
+
+	filter, ok := ownedFilter(c, user.(models.User))
+	if !ok {
+		c.AbortWithError(http.StatusNotFound, errReminderNotFound)
+		return
+	}
+
+	var existing models.Reminder
+	err := r.Server.Db.Get(ctx, filter, &existing)
+	if models.IsErrNotFound(err) {
+		c.AbortWithError(http.StatusNotFound, errReminderNotFound)
+		return
+	} else if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	input := models.ReminderFields{
+		Text:         existing.Text,
+		Frequency:    existing.Frequency,
+		Weekday:      existing.Weekday,
+		Time:         existing.Time,
+		Conversation: existing.Conversation,
+		StartChat:    existing.StartChat,
+		Disabled:     existing.Disabled,
+	}
+	err = c.ShouldBindJSON(&input)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	reminder, err := r.reminder(ctx, user.(models.User), existing, input)
+	if err != nil {
+		c.AbortWithError(reminderErrorStatus(err), err)
+		return
+	}
+
+	// Update skips false fields, all columns are replaced to allow turning flags off.
+	err = r.Server.Db.Replace(ctx, filter, &reminder)
+	if models.IsErrNotFound(err) {
+		c.AbortWithError(http.StatusNotFound, errReminderNotFound)
+		return
+	} else if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reminder)
+}
+
+// Delete godoc
+//
+//	@Summary		Delete check-in
+//	@Description	Delete check-in of authorized user
+//	@Tags			reminders
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		string	true	"Reminder ID"
+//	@Success		200	{object}	Response
+//	@Failure		404	{object}	errs.Problem
+//	@Failure		500	{object}	errs.Problem
+//	@Router			/profile/reminders/{id} [delete]
+func (r *ReminderHandler) Delete(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.AbortWithError(http.StatusUnauthorized, errs.ErrUnauthorized)
+		return
+	}
+
+	filter, ok := ownedFilter(c, user.(models.User))
+	if !ok {
+		c.AbortWithError(http.StatusNotFound, errReminderNotFound)
+		return
+	}
+
+	err := r.Server.Db.Delete(ctx, filter, &models.Reminder{})
+	if models.IsErrNotFound(err) {
+		c.AbortWithError(http.StatusNotFound, errReminderNotFound)
+		return
+	} else if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{"reminder deleted"})
+}
+
+// reminder validates the fields, checks the conversation owner and schedules the next run.
+func (r *ReminderHandler) reminder(ctx context.Context, user models.User, reminder models.Reminder, input models.ReminderFields) (models.Reminder, error) {
+	err := input.Validate()
+	if err != nil {
+		return reminder, err
+	}
+
+	if input.Conversation != "" && input.Conversation != reminder.Conversation && input.Conversation != user.Thread {
+		var filter models.FilterParams
+		filter.Filter = fmt.Sprintf(`id = '%v' and user_id = '%v'`, strings.ReplaceAll(input.Conversation, "'", "''"), user.Id.String())
+
+		var conversation models.Conversation
+		err = r.Server.Db.Get(ctx, filter, &conversation)
+		if models.IsErrNotFound(err) {
+			return reminder, models.AdvancedErrorResponse{
+				Key:     "conversation_field",
+				Code:    http.StatusBadRequest,
+				Message: "Диалог не найден.",
+			}
+		} else if err != nil {
+			return reminder, err
+		}
+	}
+
+	reminder.Text = input.Text
+	reminder.Frequency = input.Frequency
+	reminder.Weekday = input.Weekday
+	reminder.Time = input.Time
+	reminder.Conversation = input.Conversation
+	reminder.StartChat = input.StartChat
+	reminder.Disabled = input.Disabled
+
+	err = reminder.Schedule(time.Now(), models.UserLocation(user))
+	return reminder, err
+}
+
+// reminderErrorStatus is 400 for invalid input and 500 for the failed conversation lookup.
+func reminderErrorStatus(err error) int {
+	var invalid models.AdvancedErrorResponse
+	if errors.As(err, &invalid) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
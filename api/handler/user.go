@@ -1,17 +1,26 @@
 package handler
 
 import (
+	"archive/zip"
+	"bytes"
 	"chatgpt/api/middleware"
 	"chatgpt/auth"
+	"chatgpt/errs"
+	"chatgpt/i18n"
 	"chatgpt/models"
 	"chatgpt/server"
-	"errors"
+	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"net/http"
+	"strings"
 	"time"
 )
 
+var errMemoryNotFound = errs.New(errs.CodeNotFound, "memory_not_found", "memory not found")
+
 type UserHandler struct {
 	Server *server.Server
 }
@@ -21,9 +30,16 @@ func NewUserHandler(server *server.Server) *UserHandler {
 }
 
 func (u *UserHandler) Init() {
-	profile := u.Server.Router.Group("/profile", middleware.Authenticate(u.Server.Cache))
-	profile.GET("", u.Profile)
-	profile.PATCH("/update", u.Update)
+	u.Server.Handle(func(r *gin.RouterGroup) {
+		profile := r.Group("/profile", middleware.Authenticate(u.Server.Cache))
+		profile.GET("", u.Profile)
+		profile.PATCH("/update", u.Update)
+		profile.DELETE("", u.Delete)
+		profile.GET("/export", u.Export)
+		profile.GET("/memories", u.Memories)
+		profile.DELETE("/memories", u.ForgetAll)
+		profile.DELETE("/memories/:id", u.Forget)
+	})
 }
 
 // Profile godoc
@@ -35,15 +51,15 @@ func (u *UserHandler) Init() {
 //	@Produce		json
 //	@Security		BearerAuth
 //	@Success		200	{object}	models.User
-//	@Failure		400	{object}	models.AdvancedErrorResponse
-//	@Failure		500	{object}	models.ErrorResponse
+//	@Failure		400	{object}	errs.Problem
+//	@Failure		500	{object}	errs.Problem
 //	@Router			/profile [get]
 func (u *UserHandler) Profile(c *gin.Context) {
 	ctx := c.Request.Context()
 
 	cacheUser, ok := c.Get("user")
 	if !ok {
-		c.AbortWithError(http.StatusUnauthorized, errors.New("not authorized"))
+		c.AbortWithError(http.StatusUnauthorized, errs.ErrUnauthorized)
 		return
 	}
 
@@ -68,34 +84,64 @@ func (u *UserHandler) Profile(c *gin.Context) {
 //	@Accept			json
 //	@Produce		json
 //	@Security		BearerAuth
-//	@Param			rq	body		models.User	true	"User data"
+//	@Param			rq	body		models.ProfileFields	true	"User data, empty fields are kept"
 //	@Success		200	{object}	models.User
-//	@Failure		400	{object}	models.AdvancedErrorResponse
-//	@Failure		500	{object}	models.ErrorResponse
+//	@Failure		400	{object}	errs.Problem
+//	@Failure		500	{object}	errs.Problem
 //	@Router			/profile/update [patch]
 func (u *UserHandler) Update(c *gin.Context) {
 	ctx := c.Request.Context()
 
 	user, ok := c.Get("user")
 	if !ok {
-		c.AbortWithError(http.StatusUnauthorized, errors.New("not authorized"))
+		c.AbortWithError(http.StatusUnauthorized, errs.ErrUnauthorized)
 		return
 	}
 
-	var input models.User
+	// roles, the thread, login and deletion are not part of the profile, they change only through their routes
+	var input models.ProfileFields
 	err := c.ShouldBind(&input)
 	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
+		c.AbortWithError(http.StatusBadRequest, invalidBody(err))
 		return
 	}
 
+	if input.Locale != "" && !i18n.Supported(input.Locale) {
+		c.AbortWithError(http.StatusBadRequest, models.AdvancedErrorResponse{
+			Key:     "locale_field",
+			Code:    http.StatusBadRequest,
+			Message: "Поле 'locale' содержит неподдерживаемый язык.",
+		})
+		return
+	}
+
+	if input.Timezone != "" {
+		_, err = time.LoadLocation(input.Timezone)
+		if err != nil {
+			c.AbortWithError(http.StatusBadRequest, models.AdvancedErrorResponse{
+				Key:     "timezone_field",
+				Code:    http.StatusBadRequest,
+				Message: "Поле 'timezone' должно быть часовым поясом IANA.",
+			})
+			return
+		}
+	}
+
 	var filter models.FilterParams
 	filter.Filter = fmt.Sprintf(`id = '%v'`, user.(models.User).Id.String())
 
-	err = u.Server.Db.Update(ctx, filter, &input)
-	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
-		return
+	// an update without fields matches no row, there is nothing to change then
+	if input != (models.ProfileFields{}) {
+		err = u.Server.Db.Update(ctx, filter, &models.User{
+			Name:     input.Name,
+			Surname:  input.Surname,
+			Timezone: input.Timezone,
+			Locale:   input.Locale,
+		})
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
 	}
 
 	var updatedUser models.User
@@ -105,9 +151,17 @@ func (u *UserHandler) Update(c *gin.Context) {
 		return
 	}
 
+	if input.Timezone != "" && input.Timezone != user.(models.User).Timezone {
+		err = u.reschedule(ctx, updatedUser)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+	}
+
 	//TODO move through user tokens table and change them
 	token, _ := c.Get("token")
-	err = u.Server.Cache.SetHash(ctx, auth.RedisAccessPath+token.(string), updatedUser, 24*time.Hour)
+	err = u.Server.Cache.SetHash(ctx, auth.RedisAccessPath+token.(string), updatedUser, auth.AccessTokenTTL)
 	if err != nil {
 		c.AbortWithError(http.StatusInternalServerError, err)
 		return
@@ -115,3 +169,368 @@ func (u *UserHandler) Update(c *gin.Context) {
 
 	c.JSON(http.StatusOK, updatedUser)
 }
+
+// reschedule moves reminders of the user to the local time of the new time zone.
+func (u *UserHandler) reschedule(ctx context.Context, user models.User) error {
+	var filter models.FilterParams
+	filter.Filter = fmt.Sprintf(`user_id = '%v'`, user.Id.String())
+
+	var reminders []models.Reminder
+	err := u.Server.Db.Get(ctx, filter, &reminders)
+	if err != nil {
+		return models.AllowErrNotFound(err)
+	}
+
+	now := time.Now()
+	location := models.UserLocation(user)
+	for _, reminder := range reminders {
+		err = reminder.Schedule(now, location)
+		if err != nil {
+			return err
+		}
+
+		filter.Filter = fmt.Sprintf(`id = '%v'`, reminder.Id.String())
+		err = u.Server.Db.Update(ctx, filter, &models.Reminder{NextRunAt: reminder.NextRunAt})
+		if models.AllowErrNotFound(err) != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type DeleteResponse struct {
+	DeleteAt time.Time `json:"deleteAt"`
+}
+
+// Delete godoc
+//
+//	@Summary		Delete user account
+//	@Description	Schedules the account with all chat history for deletion after the grace period and revokes all sessions, logging in again within the grace period cancels the deletion
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		202	{object}	DeleteResponse
+//	@Failure		400	{object}	errs.Problem
+//	@Failure		500	{object}	errs.Problem
+//	@Router			/profile [delete]
+func (u *UserHandler) Delete(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.AbortWithError(http.StatusUnauthorized, errs.ErrUnauthorized)
+		return
+	}
+
+	var filter models.FilterParams
+	filter.Filter = fmt.Sprintf(`id = '%v'`, user.(models.User).Id.String())
+
+	deleteAt := time.Now().UTC().Add(time.Duration(u.Server.Configuration.AccountDeletionGraceHours) * time.Hour)
+	err := u.Server.Db.Update(ctx, filter, &models.User{DeleteAt: &deleteAt})
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	err = auth.RevokeUserTokens(ctx, u.Server.Cache, user.(models.User).Id.String())
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, DeleteResponse{deleteAt})
+}
+
+type ExportConversation struct {
+	Id       string           `json:"id"`
+	Messages []models.Message `json:"messages"`
+}
+
+type ExportArchive struct {
+	Profile       models.User           `json:"profile"`
+	Conversations []ExportConversation  `json:"conversations"`
+	Memories      MemoriesResponse      `json:"memories"`
+	Moods         []models.MoodEntry    `json:"moods"`
+	Journal       []models.JournalEntry `json:"journal"`
+	ExportedAt    time.Time             `json:"exportedAt"`
+}
+
+// Export godoc
+//
+//	@Summary		Export user data
+//	@Description	Export profile and full chat history as JSON or as ZIP archive
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Produce		application/zip
+//	@Security		BearerAuth
+//	@Param			format	query		string	false	"Archive format"	Enums(json, zip)
+//	@Success		200		{object}	ExportArchive
+//	@Failure		400		{object}	errs.Problem
+//	@Failure		500		{object}	errs.Problem
+//	@Router			/profile/export [get]
+func (u *UserHandler) Export(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	cacheUser, ok := c.Get("user")
+	if !ok {
+		c.AbortWithError(http.StatusUnauthorized, errs.ErrUnauthorized)
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "zip" {
+		c.AbortWithError(http.StatusBadRequest, models.AdvancedErrorResponse{
+			Key:     "format_field",
+			Code:    http.StatusBadRequest,
+			Message: "Поле 'format' должно быть 'json' или 'zip'.",
+		})
+		return
+	}
+
+	var filter models.FilterParams
+	filter.Filter = fmt.Sprintf(`id = '%v'`, cacheUser.(models.User).Id.String())
+
+	var user models.User
+	err := u.Server.Db.Get(ctx, filter, &user)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	archive := ExportArchive{
+		Profile:       user,
+		Conversations: make([]ExportConversation, 0),
+		Moods:         make([]models.MoodEntry, 0),
+		Journal:       make([]models.JournalEntry, 0),
+		ExportedAt:    time.Now().UTC(),
+	}
+
+	conversations, err := models.UserConversations(ctx, u.Server.Db, user)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	for _, id := range conversations {
+		messages, err := u.Server.AI.GetAllMessages(ctx, id)
+		if err != nil && strings.Contains(err.Error(), "status code: 404") {
+			// thread expired on the OpenAI side.
+			continue
+		} else if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		archive.Conversations = append(archive.Conversations, ExportConversation{id, messages})
+	}
+
+	archive.Memories, err = u.memories(ctx, user.Id.String())
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	filter.Filter = fmt.Sprintf(`user_id = '%v'`, user.Id.String())
+	filter.Orderings = "created_at"
+
+	err = u.Server.Db.Get(ctx, filter, &archive.Moods)
+	if models.AllowErrNotFound(err) != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	err = u.Server.Db.Get(ctx, filter, &archive.Journal)
+	if models.AllowErrNotFound(err) != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	if format == "json" {
+		c.Header("Content-Disposition", `attachment; filename="thera-chat-export.json"`)
+		c.JSON(http.StatusOK, archive)
+		return
+	}
+
+	data, err := zipArchive(archive)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="thera-chat-export.zip"`)
+	c.Data(http.StatusOK, "application/zip", data)
+}
+
+// zipArchive stores the profile and every conversation as separate JSON files.
+func zipArchive(archive ExportArchive) ([]byte, error) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+
+	files := map[string]interface{}{
+		"profile.json":  archive.Profile,
+		"memories.json": archive.Memories,
+		"moods.json":    archive.Moods,
+		"journal.json":  archive.Journal,
+	}
+	for _, conversation := range archive.Conversations {
+		files["conversations/"+conversation.Id+".json"] = conversation.Messages
+	}
+
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			return nil, err
+		}
+
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(content)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := w.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+type MemoriesResponse struct {
+	Facts     []models.Memory              `json:"facts"`
+	Summaries []models.ConversationSummary `json:"summaries"`
+}
+
+func (u *UserHandler) memories(ctx context.Context, userId string) (MemoriesResponse, error) {
+	var filter models.FilterParams
+	filter.Filter = fmt.Sprintf(`user_id = '%v'`, userId)
+	filter.Orderings = "created_at desc"
+
+	memories := MemoriesResponse{
+		Facts:     make([]models.Memory, 0),
+		Summaries: make([]models.ConversationSummary, 0),
+	}
+
+	err := u.Server.Db.Get(ctx, filter, &memories.Facts)
+	if models.AllowErrNotFound(err) != nil {
+		return memories, err
+	}
+
+	filter.Orderings = "updated_at desc"
+	err = u.Server.Db.Get(ctx, filter, &memories.Summaries)
+	if models.AllowErrNotFound(err) != nil {
+		return memories, err
+	}
+
+	return memories, nil
+}
+
+// Memories godoc
+//
+//	@Summary		Get user memories
+//	@Description	Facts the bot remembers about the user and summaries of conversations
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	MemoriesResponse
+//	@Failure		500	{object}	errs.Problem
+//	@Router			/profile/memories [get]
+func (u *UserHandler) Memories(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.AbortWithError(http.StatusUnauthorized, errs.ErrUnauthorized)
+		return
+	}
+
+	memories, err := u.memories(ctx, user.(models.User).Id.String())
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, memories)
+}
+
+// Forget godoc
+//
+//	@Summary		Delete memory
+//	@Description	Delete one fact the bot remembers about the user
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		string	true	"Memory ID"
+//	@Success		200	{object}	Response
+//	@Failure		404	{object}	errs.Problem
+//	@Failure		500	{object}	errs.Problem
+//	@Router			/profile/memories/{id} [delete]
+func (u *UserHandler) Forget(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.AbortWithError(http.StatusUnauthorized, errs.ErrUnauthorized)
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithError(http.StatusNotFound, errMemoryNotFound)
+		return
+	}
+
+	var filter models.FilterParams
+	filter.Filter = fmt.Sprintf(`id = '%v' and user_id = '%v'`, id.String(), user.(models.User).Id.String())
+
+	err = u.Server.Db.Delete(ctx, filter, &models.Memory{})
+	if models.IsErrNotFound(err) {
+		c.AbortWithError(http.StatusNotFound, errMemoryNotFound)
+		return
+	} else if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{"memory deleted"})
+}
+
+// ForgetAll godoc
+//
+//	@Summary		Delete all memories
+//	@Description	Delete all facts and conversation summaries the bot remembers about the user
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	Response
+//	@Failure		500	{object}	errs.Problem
+//	@Router			/profile/memories [delete]
+func (u *UserHandler) ForgetAll(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.AbortWithError(http.StatusUnauthorized, errs.ErrUnauthorized)
+		return
+	}
+
+	var filter models.FilterParams
+	filter.Filter = fmt.Sprintf(`user_id = '%v'`, user.(models.User).Id.String())
+
+	for _, table := range []interface{}{&models.Memory{}, &models.ConversationSummary{}} {
+		err := u.Server.Db.Delete(ctx, filter, table)
+		if models.AllowErrNotFound(err) != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, Response{"memories deleted"})
+}
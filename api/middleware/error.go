@@ -1,37 +1,44 @@
 package middleware
 
 import (
-	"chatgpt/models"
-	"errors"
-	"fmt"
+	"chatgpt/errs"
 	"github.com/gin-gonic/gin"
+	"log/slog"
 )
 
-// Can pass the logger
-func ErrorHandler() gin.HandlerFunc {
+// deferredWriter postpones the header of aborted requests until the body is written,
+// so ErrorHandler still decides the status and headers of the error response.
+type deferredWriter struct {
+	gin.ResponseWriter
+}
+
+func (w *deferredWriter) WriteHeaderNow() {}
+
+// ErrorHandler writes the last error of the request as a problem body,
+// causes of all errors are only logged.
+func ErrorHandler(logger *slog.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		c.Writer = &deferredWriter{c.Writer}
 		c.Next()
 
-		var err error
-		for _, err = range c.Errors {
-			// Adding stuck trace should be done without error handler middleware
-			// so maybe error handler should be replaced to separate function
-			// or stacks can be added to the error itself, so it has to be explored and tested
-			fmt.Errorf("error: %v", err.Error())
+		if len(c.Errors) == 0 {
+			return
 		}
 
-		// status -1 doesn't overwrite existing status code
-		if err != nil {
-			var errResponse models.ErrorResponse
-			var errAdvanced models.AdvancedErrorResponse
-			switch {
-			case errors.As(err, &errResponse):
-				c.JSON(-1, gin.H{"error": models.ErrorResponse{Code: errResponse.Code, Message: errResponse.Message}})
-			case errors.As(err, &errAdvanced):
-				c.JSON(-1, gin.H{errAdvanced.Key: models.AdvancedErrorResponse{Code: errAdvanced.Code, Message: errAdvanced.Message}})
-			default:
-				c.JSON(-1, gin.H{"error": models.ErrorResponse{Code: c.Writer.Status(), Message: err.Error()}})
-			}
+		for _, err := range c.Errors {
+			logger.WarnContext(c.Request.Context(), "request error",
+				"method", c.Request.Method, "route", c.FullPath(), "error", err.Err)
 		}
+
+		if c.Writer.Written() {
+			return
+		}
+
+		err := errs.From(c.Errors.Last().Err, c.Writer.Status())
+		locale := Locale(c)
+
+		c.Header("Content-Language", locale)
+		c.Header("Content-Type", errs.ProblemContentType)
+		c.JSON(err.Code.Status(), err.Problem(locale, c.Request.URL.Path))
 	}
 }
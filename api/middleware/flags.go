@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"chatgpt/errs"
+	"chatgpt/flags"
+	"chatgpt/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+// SessionHeader identifies clients that are not logged in, so percentage rollouts are stable for them too.
+const SessionHeader = "X-Session-ID"
+
+var errFeatureDisabled = errs.New(errs.CodeNotFound, "feature_disabled", "feature is not available")
+
+// Features makes the feature flags available to Feature and RequireFeature.
+func Features(service *flags.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("features", service)
+		c.Next()
+	}
+}
+
+// Feature tells if the flag is on for the user of the request, or for its anonymous session
+// on routes without authentication.
+func Feature(c *gin.Context, key string) bool {
+	service, ok := c.Get("features")
+	if !ok {
+		return false
+	}
+	return service.(*flags.Service).Enabled(c.Request.Context(), key, subject(c))
+}
+
+// RequireFeature hides the route while the flag is off for the request.
+func RequireFeature(key string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !Feature(c, key) {
+			c.AbortWithError(http.StatusNotFound, errFeatureDisabled)
+			return
+		}
+		c.Next()
+	}
+}
+
+func subject(c *gin.Context) flags.Subject {
+	if user, ok := c.Get("user"); ok {
+		var roles []string
+		for _, role := range strings.Split(user.(models.User).Roles, ",") {
+			roles = append(roles, strings.TrimSpace(role))
+		}
+		return flags.Subject{Id: user.(models.User).Id.String(), Roles: roles}
+	}
+
+	if session := c.GetHeader(SessionHeader); requestIdPattern.MatchString(session) {
+		return flags.Subject{Id: "session:" + session}
+	}
+	return flags.Subject{}
+}
@@ -0,0 +1,197 @@
+package middleware
+
+import (
+	"bytes"
+	"chatgpt/errs"
+	"chatgpt/models"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader marks responses replayed from an earlier request with the same key.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	// RedisIdempotencyPath prefixes the stored requests, the scope of the key follows.
+	RedisIdempotencyPath = "idempotency:"
+
+	// idempotencyLockTTL frees the key of a request that never finished, e.g. after a crash.
+	// The lock of a running request is extended every idempotencyLockRefresh, however long it runs.
+	idempotencyLockTTL     = time.Minute
+	idempotencyLockRefresh = idempotencyLockTTL / 3
+)
+
+var (
+	errIdempotencyKeyInvalid = errs.New(errs.CodeValidation, "idempotency_key_invalid", "invalid Idempotency-Key header")
+	errIdempotencyKeyReused  = errs.New(errs.CodeUnprocessable, "idempotency_key_reused", "Idempotency-Key was used for a different request")
+	errIdempotencyInProgress = errs.New(errs.CodeConflict, "idempotency_in_progress", "request with the Idempotency-Key is in progress")
+)
+
+// idempotentRequest is stored under the key, first while the request runs and then with its response.
+type idempotentRequest struct {
+	// Fingerprint of the method, path and body, a key is valid for one request only.
+	Fingerprint string `json:"fingerprint"`
+	Done        bool   `json:"done"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency makes retries of a request with the same Idempotency-Key header safe. The first request runs
+// and its response is stored for ttl, retries get the stored response replayed, 409 while the first one runs
+// and 422 if the key comes with another method, path or body. Failed requests, errors and 5xx, are not stored,
+// so their retries run again. Requests without the header are not affected. Keys are scoped to the user if
+// the route follows Authenticate, so it must come after it and after BodyLimit, otherwise to the X-Session-ID
+// header, the :id conversation of the path or the client address.
+func Idempotency(cache models.CacheClient, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if !requestIdPattern.MatchString(key) {
+			c.AbortWithError(http.StatusBadRequest, errIdempotencyKeyInvalid)
+			return
+		}
+
+		ctx := c.Request.Context()
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.New()
+		hash.Write([]byte(c.Request.Method + " " + c.Request.URL.Path + "\n"))
+		hash.Write(body)
+		fingerprint := hex.EncodeToString(hash.Sum(nil))
+
+		redisKey := RedisIdempotencyPath + idempotencyScope(c) + ":" + key
+
+		lock := idempotentRequest{Fingerprint: fingerprint}
+		acquired, err := cache.SetHashNX(ctx, redisKey, lock, idempotencyLockTTL)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		if !acquired {
+			var stored idempotentRequest
+			err = cache.GetHash(ctx, redisKey, &stored)
+			switch {
+			case models.IsErrNotFound(err):
+				// the first request failed meanwhile, the client retries once more
+				c.Header("Retry-After", "1")
+				c.AbortWithError(http.StatusConflict, errIdempotencyInProgress)
+			case err != nil:
+				c.AbortWithError(http.StatusInternalServerError, err)
+			case stored.Fingerprint != fingerprint:
+				c.AbortWithError(http.StatusUnprocessableEntity, errIdempotencyKeyReused)
+			case !stored.Done:
+				c.Header("Retry-After", "1")
+				c.AbortWithError(http.StatusConflict, errIdempotencyInProgress)
+			default:
+				c.Header(IdempotentReplayedHeader, "true")
+				c.Data(stored.Status, stored.ContentType, stored.Body)
+				c.Abort()
+			}
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		release := holdLock(ctx, cache, redisKey, lock)
+		c.Next()
+		release()
+		c.Writer = recorder.ResponseWriter
+
+		// the request may be cancelled, the key is released or stored anyway
+		ctx = context.WithoutCancel(ctx)
+
+		status := c.Writer.Status()
+		if len(c.Errors) > 0 || status >= http.StatusInternalServerError {
+			err = cache.DeleteHash(ctx, redisKey)
+		} else {
+			err = cache.SetHash(ctx, redisKey, idempotentRequest{
+				Fingerprint: fingerprint,
+				Done:        true,
+				Status:      status,
+				ContentType: c.Writer.Header().Get("Content-Type"),
+				Body:        recorder.body.Bytes(),
+			}, ttl)
+		}
+		if err != nil {
+			_ = c.Error(err)
+		}
+	}
+}
+
+// idempotencyScope keeps the keys of different clients apart, the user, the anonymous session, the anonymous
+// conversation or the client address.
+func idempotencyScope(c *gin.Context) string {
+	if user, ok := c.Get("user"); ok {
+		return user.(models.User).Id.String()
+	}
+	if session := c.GetHeader(SessionHeader); requestIdPattern.MatchString(session) {
+		return "session:" + session
+	}
+	if id := c.Param("id"); id != "" {
+		return "conversation:" + id
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// holdLock extends the lock of the running request until release is called, so the key is not freed
+// while a slow request still runs.
+func holdLock(ctx context.Context, cache models.CacheClient, key string, lock idempotentRequest) (release func()) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(idempotencyLockRefresh)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := cache.SetHash(ctx, key, lock, idempotencyLockTTL)
+				if err != nil && ctx.Err() == nil {
+					slog.WarnContext(ctx, "extend idempotency lock", "key", key, "error", err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"chatgpt/i18n"
+	"chatgpt/models"
+	"github.com/gin-gonic/gin"
+)
+
+// Locale negotiates the locale of the request, the user preference wins over Accept-Language.
+func Locale(c *gin.Context) string {
+	var preferred string
+	if user, ok := c.Get("user"); ok {
+		preferred = user.(models.User).Locale
+	}
+	return i18n.Negotiate(preferred, c.GetHeader("Accept-Language"))
+}
@@ -2,8 +2,8 @@ package middleware
 
 import (
 	"chatgpt/auth"
+	"chatgpt/errs"
 	"chatgpt/models"
-	"errors"
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"strings"
@@ -23,7 +23,7 @@ func Authenticate(cache models.CacheClient) gin.HandlerFunc {
 		// header format validation.
 		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
 			// log: invalid authentication token response(c)
-			c.AbortWithError(http.StatusUnauthorized, errors.New("no Authorization token"))
+			c.AbortWithError(http.StatusUnauthorized, errs.ErrNoToken)
 			return
 		}
 		token := headerParts[1]
@@ -44,6 +44,26 @@ func Authenticate(cache models.CacheClient) gin.HandlerFunc {
 	}
 }
 
+// RequireRole must follow Authenticate and allows only users with the role.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := c.Get("user")
+		if !ok {
+			c.AbortWithError(http.StatusUnauthorized, errs.ErrUnauthorized)
+			return
+		}
+
+		for _, r := range strings.Split(user.(models.User).Roles, ",") {
+			if strings.TrimSpace(r) == role {
+				c.Next()
+				return
+			}
+		}
+
+		c.AbortWithError(http.StatusForbidden, errs.ErrForbidden)
+	}
+}
+
 // TODO refactor error handling
 func JSONMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"chatgpt/logging"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+const RequestIdHeader = "X-Request-ID"
+
+// requestIdPattern limits IDs accepted from clients, so they are safe to log and forward.
+var requestIdPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// RequestId takes the ID of the request from the header or generates one,
+// returns it in the response and stores it in the request context for logs and outgoing calls.
+func RequestId() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIdHeader)
+		if !requestIdPattern.MatchString(id) {
+			id = uuid.NewString()
+		}
+
+		c.Header(RequestIdHeader, id)
+		c.Set("requestId", id)
+		c.Request = c.Request.WithContext(logging.WithRequestId(c.Request.Context(), id))
+
+		c.Next()
+	}
+}
+
+// Logger writes one record per request, server errors as errors and client errors as warnings.
+func Logger(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		logger.Log(c.Request.Context(), level, "request",
+			"method", c.Request.Method,
+			"route", c.FullPath(),
+			"status", status,
+			"latency", time.Since(start),
+			"size", c.Writer.Size(),
+			"ip", c.ClientIP(),
+		)
+	}
+}
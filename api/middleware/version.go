@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+// Deprecated marks the responses of routes replaced by versioned ones. The Link header points to the
+// same route under successor, a prefix like /v1. Deprecation is the date of deprecation, or true if it is zero,
+// and Sunset is sent if the date of removal is known.
+func Deprecated(successor string, deprecatedAt time.Time, sunsetAt time.Time) gin.HandlerFunc {
+	deprecation := "true"
+	if !deprecatedAt.IsZero() {
+		deprecation = fmt.Sprintf("@%v", deprecatedAt.Unix())
+	}
+
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		header.Set("Deprecation", deprecation)
+		if !sunsetAt.IsZero() {
+			header.Set("Sunset", sunsetAt.UTC().Format(http.TimeFormat))
+		}
+		header.Set("Link", fmt.Sprintf(`<%v%v>; rel="successor-version"`, successor, c.Request.URL.Path))
+
+		c.Next()
+	}
+}
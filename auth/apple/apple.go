@@ -3,8 +3,8 @@ package apple
 import (
 	"chatgpt/config"
 	"context"
-	"fmt"
 	"github.com/Timothylock/go-signin-with-apple/apple"
+	"log/slog"
 	"strings"
 )
 
@@ -63,7 +63,7 @@ func (a AppleAuthenticator) ValidateAuthorizationToken(token string, isAndroid b
 	}
 
 	if resp.Error != "" {
-		fmt.Printf("apple returned an error: %s - %s\n", resp.Error, resp.ErrorDescription)
+		slog.Warn("apple token validation", "error", resp.Error, "description", resp.ErrorDescription)
 		if err != nil {
 			return nil, err
 		}
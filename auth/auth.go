@@ -1,12 +1,12 @@
 package auth
 
 import (
+	"chatgpt/errs"
 	"chatgpt/models"
 	"context"
 	"crypto/rand"
 	"crypto/sha512"
 	"encoding/base32"
-	"errors"
 	"time"
 )
 
@@ -19,6 +19,14 @@ const (
 	RedisAccessPath  = "access/"
 	RedisRefreshPath = "refresh/"
 	RedisCodePath    = "code/"
+	RedisThreadPath  = "thread/"
+	// RedisTokensPath is the set of the token keys issued to a user, the user ID follows.
+	RedisTokensPath = "tokens/"
+)
+
+const (
+	AccessTokenTTL  = 24 * time.Hour
+	RefreshTokenTTL = 7 * 24 * time.Hour
 )
 
 // Stores data about Token struct. Plaintext is return token value.
@@ -62,12 +70,12 @@ func generateToken(uuid string, expiration time.Duration, key string, scope stri
 // Returns authorization tokens.
 func GetAuthTokens(uuid string, accessKey string, refreshKey string) (accessToken *Token, refreshToken *Token, err error) {
 
-	accessToken, err = generateToken(uuid, 24*time.Hour, accessKey, ScopeAccess)
+	accessToken, err = generateToken(uuid, AccessTokenTTL, accessKey, ScopeAccess)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	refreshToken, err = generateToken(uuid, 7*24*time.Hour, refreshKey, ScopeRefresh)
+	refreshToken, err = generateToken(uuid, RefreshTokenTTL, refreshKey, ScopeRefresh)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -85,10 +93,47 @@ func GetUserByToken(ctx context.Context, cache models.CacheClient, tokenPlainTex
 	//key := hex.EncodeToString(tokenHash[:])
 
 	err := cache.GetHash(ctx, RedisAccessPath+tokenPlainText, user)
+	if models.IsErrNotFound(err) {
+		return errs.Wrap(err, errs.CodeUnauthorized, errs.ErrInvalidToken.Key, errs.ErrInvalidToken.Message)
+	}
 	if err != nil {
-		// log: No such token.
-		return errors.New("no such token")
+		return err
 	}
 
 	return nil
 }
+
+// Stores the tokens of the user and adds them to the user's token set, so they can be revoked.
+func StoreAuthTokens(ctx context.Context, cache models.CacheClient, user models.User, access *Token, refresh *Token) error {
+	err := cache.SetHash(ctx, RedisAccessPath+access.Plaintext, user, AccessTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	err = cache.SetHash(ctx, RedisRefreshPath+refresh.Plaintext, user, RefreshTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	// the set lives as long as the newest refresh token, keys of expired tokens in it are harmless.
+	return cache.AddToSet(ctx, RedisTokensPath+user.Id.String(), RefreshTokenTTL,
+		RedisAccessPath+access.Plaintext, RedisRefreshPath+refresh.Plaintext)
+}
+
+// Revokes every access and refresh token issued to the user.
+func RevokeUserTokens(ctx context.Context, cache models.CacheClient, userId string) error {
+	var keys []string
+	err := cache.GetSet(ctx, RedisTokensPath+userId, &keys)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		err = cache.DeleteHash(ctx, key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return cache.DeleteHash(ctx, RedisTokensPath+userId)
+}
@@ -11,9 +11,14 @@ type FirebaseAuthenticator struct {
 	*auth.Client
 }
 
-func NewFirebaseAuthenticator(ctx context.Context) (*FirebaseAuthenticator, error) {
-	opt := option.WithCredentialsFile("./thera-chat-firebase.json")
-	app, err := firebase.NewApp(ctx, nil, opt)
+// NewApp initializes the Firebase app with the service account credentials file.
+func NewApp(ctx context.Context, credentialsPath string) (*firebase.App, error) {
+	opt := option.WithCredentialsFile(credentialsPath)
+	return firebase.NewApp(ctx, nil, opt)
+}
+
+func NewFirebaseAuthenticator(ctx context.Context, credentialsPath string) (*FirebaseAuthenticator, error) {
+	app, err := NewApp(ctx, credentialsPath)
 	if err != nil {
 		return nil, err
 	}
@@ -4,7 +4,6 @@ import (
 	"chatgpt/config"
 	"context"
 	"errors"
-	"fmt"
 	"google.golang.org/api/idtoken"
 	"strings"
 )
@@ -62,7 +61,6 @@ func (g GoogleAuthenticator) ValidateIdToken(token string) (*GoogleUser, error)
 		user.LastName = lastName.(string)
 	}
 
-	fmt.Println(user)
 	return user, nil
 }
 
@@ -0,0 +1,94 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// reloadInterval is how often the files are checked for a renewed certificate.
+const reloadInterval = 30 * time.Second
+
+// Reloader serves the certificate of a cert and key file pair and picks up renewed files
+// without a restart. An invalid renewal is logged and the previous certificate stays in use.
+type Reloader struct {
+	certFile string
+	keyFile  string
+
+	cert    atomic.Pointer[tls.Certificate]
+	modTime time.Time
+}
+
+// NewReloader loads the certificate, it fails if the files are missing or invalid.
+func NewReloader(certFile string, keyFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile}
+	_, err := r.reload()
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate is the tls.Config callback serving the current certificate.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
+
+// Run checks the files every reloadInterval until ctx is cancelled.
+func (r *Reloader) Run(ctx context.Context) {
+	ticker := time.NewTicker(reloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		reloaded, err := r.reload()
+		if err != nil {
+			slog.ErrorContext(ctx, "reload certificate", "error", err)
+		} else if reloaded {
+			slog.InfoContext(ctx, "certificate reloaded", "certFile", r.certFile)
+		}
+	}
+}
+
+// reload loads the files if either of them changed since the last load.
+func (r *Reloader) reload() (bool, error) {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return false, err
+	}
+	if !modTime.After(r.modTime) {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("certificate %v: %w", r.certFile, err)
+	}
+
+	r.cert.Store(&cert)
+	r.modTime = modTime
+	return true, nil
+}
+
+func (r *Reloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("certificate: %w", err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,116 @@
+// Package client is a typed client of the v1 API for integration tests and internal tools.
+// The types and operations in client_gen.go are generated from the swagger spec,
+// run go generate after swag init.
+package client
+
+//go:generate go run ./gen 1 client_gen.go
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type Client struct {
+	// BaseURL of the API, e.g. http://localhost:8080.
+	BaseURL string
+	// Token is sent as the bearer token if set.
+	Token string
+	// Locale is sent as Accept-Language if set, it selects the language of problem details.
+	Locale     string
+	HTTPClient *http.Client
+}
+
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/"), HTTPClient: http.DefaultClient}
+}
+
+// WithToken returns a copy of the client authorized with the token.
+func (c *Client) WithToken(token string) *Client {
+	copied := *c
+	copied.Token = token
+	return &copied
+}
+
+// Error is a failed request, Problem holds the problem details of the API.
+type Error struct {
+	StatusCode int
+	Problem    Problem
+}
+
+func (e *Error) Error() string {
+	if e.Problem.Code == "" {
+		return fmt.Sprintf("api: status %v", e.StatusCode)
+	}
+	return fmt.Sprintf("api: status %v: %v (%v)", e.StatusCode, e.Problem.Code, e.Problem.Detail)
+}
+
+type idempotencyKey struct{}
+
+// WithIdempotencyKey returns a context sending the key in the Idempotency-Key header, retries of a chat or
+// auth request with the same key get the response of the first one instead of running it again.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, body any, out any) error {
+	target := c.BaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(content)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	if c.Locale != "" {
+		req.Header.Set("Accept-Language", c.Locale)
+	}
+	if key, ok := ctx.Value(idempotencyKey{}).(string); ok && key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		apiErr := &Error{StatusCode: resp.StatusCode}
+		// bodies that are not problem details leave it empty
+		_ = json.NewDecoder(resp.Body).Decode(&apiErr.Problem)
+		return apiErr
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
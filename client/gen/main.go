@@ -0,0 +1,382 @@
+// Command gen writes the typed client of an API version from its swagger spec, run it through go generate in client.
+package main
+
+import (
+	"bytes"
+	"chatgpt/server"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type schema struct {
+	Type                 string            `json:"type"`
+	Ref                  string            `json:"$ref"`
+	Description          string            `json:"description"`
+	Items                *schema           `json:"items"`
+	AllOf                []schema          `json:"allOf"`
+	Properties           map[string]schema `json:"properties"`
+	AdditionalProperties *schema           `json:"additionalProperties"`
+	Enum                 []string          `json:"enum"`
+	EnumNames            []string          `json:"x-enum-varnames"`
+}
+
+type parameter struct {
+	In       string  `json:"in"`
+	Name     string  `json:"name"`
+	Type     string  `json:"type"`
+	Required bool    `json:"required"`
+	Schema   *schema `json:"schema"`
+}
+
+type operation struct {
+	Summary    string      `json:"summary"`
+	Parameters []parameter `json:"parameters"`
+	Responses  map[string]struct {
+		Schema *schema `json:"schema"`
+	} `json:"responses"`
+}
+
+type spec struct {
+	Paths       map[string]map[string]operation `json:"paths"`
+	Definitions map[string]schema               `json:"definitions"`
+}
+
+var (
+	pathParam      = regexp.MustCompile(`\{([A-Za-z0-9_]+)\}`)
+	versionSegment = regexp.MustCompile(`^v[0-9]+$`)
+)
+
+func main() {
+	if len(os.Args) != 3 {
+		fmt.Fprintln(os.Stderr, "usage: gen version output.go")
+		os.Exit(2)
+	}
+
+	version, err := strconv.Atoi(os.Args[1])
+	if err == nil {
+		err = run(version, os.Args[2])
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(version int, output string) error {
+	doc, err := server.Spec(version)
+	if err != nil {
+		return err
+	}
+
+	var s spec
+	err = json.Unmarshal([]byte(doc), &s)
+	if err != nil {
+		return fmt.Errorf("spec: %w", err)
+	}
+
+	g := &generator{spec: s, names: make(map[string]string)}
+	err = g.nameTypes()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range sortedKeys(s.Definitions) {
+		g.definition(name, s.Definitions[name])
+	}
+
+	err = g.operations()
+	if err != nil {
+		return err
+	}
+
+	var file bytes.Buffer
+	fmt.Fprintf(&file, "// Code generated by client/gen from the v%v swagger spec. DO NOT EDIT.\n\n", version)
+	fmt.Fprintf(&file, "package client\n\nimport (\n")
+	// only the packages the code uses
+	for _, pkg := range []string{"context", "net/url", "strconv"} {
+		name := pkg[strings.LastIndex(pkg, "/")+1:]
+		if bytes.Contains(g.buf.Bytes(), []byte(name+".")) {
+			fmt.Fprintf(&file, "%q\n", pkg)
+		}
+	}
+	fmt.Fprintf(&file, ")\n\n")
+	file.Write(g.buf.Bytes())
+
+	formatted, err := format.Source(file.Bytes())
+	if err != nil {
+		return fmt.Errorf("format: %w", err)
+	}
+	return os.WriteFile(output, formatted, 0o644)
+}
+
+type generator struct {
+	spec  spec
+	names map[string]string
+	buf   bytes.Buffer
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// nameTypes drops the package of the definitions, models.Message is Message, and fails on clashes.
+func (g *generator) nameTypes() error {
+	owners := make(map[string]string)
+	for definition := range g.spec.Definitions {
+		_, name, _ := strings.Cut(definition, ".")
+		if name == "" {
+			name = definition
+		}
+		name = exported(name)
+		if owner, ok := owners[name]; ok {
+			return fmt.Errorf("definitions %v and %v are both named %v", owner, definition, name)
+		}
+		owners[name] = definition
+		g.names[definition] = name
+	}
+	return nil
+}
+
+func (g *generator) definition(definition string, s schema) {
+	name := g.names[definition]
+	comment(&g.buf, s.Description)
+
+	if len(s.Enum) > 0 {
+		g.printf("type %v string\n\nconst (\n", name)
+		for i, value := range s.Enum {
+			constant := name + exported(value)
+			if i < len(s.EnumNames) {
+				constant = s.EnumNames[i]
+			}
+			g.printf("%v %v = %q\n", constant, name, value)
+		}
+		g.printf(")\n\n")
+		return
+	}
+
+	if s.Type != "object" || s.Properties == nil {
+		g.printf("type %v %v\n\n", name, g.goType(&s))
+		return
+	}
+
+	g.printf("type %v struct {\n", name)
+	for _, property := range sortedKeys(s.Properties) {
+		p := s.Properties[property]
+		comment(&g.buf, p.Description)
+		g.printf("%v %v `json:%q`\n", exported(property), g.goType(&p), property)
+	}
+	g.printf("}\n\n")
+}
+
+func (g *generator) goType(s *schema) string {
+	if s == nil {
+		return "any"
+	}
+	if s.Ref != "" {
+		return g.names[strings.TrimPrefix(s.Ref, "#/definitions/")]
+	}
+	if len(s.AllOf) == 1 {
+		return g.goType(&s.AllOf[0])
+	}
+
+	switch s.Type {
+	case "string":
+		return "string"
+	case "integer":
+		return "int"
+	case "number":
+		return "float64"
+	case "boolean":
+		return "bool"
+	case "array":
+		return "[]" + g.goType(s.Items)
+	case "object":
+		if s.AdditionalProperties != nil {
+			return "map[string]" + g.goType(s.AdditionalProperties)
+		}
+	}
+	return "any"
+}
+
+type method struct {
+	name      string
+	httpName  string
+	path      string
+	operation operation
+}
+
+func (g *generator) operations() error {
+	var methods []method
+	owners := make(map[string]string)
+	for _, path := range sortedKeys(g.spec.Paths) {
+		for _, httpMethod := range sortedKeys(g.spec.Paths[path]) {
+			name := operationName(httpMethod, path)
+			key := strings.ToUpper(httpMethod) + " " + path
+			if owner, ok := owners[name]; ok {
+				return fmt.Errorf("operations %v and %v are both named %v", owner, key, name)
+			}
+			owners[name] = key
+			methods = append(methods, method{name, strings.ToUpper(httpMethod), path, g.spec.Paths[path][httpMethod]})
+		}
+	}
+
+	for _, m := range methods {
+		g.method(m)
+	}
+	return nil
+}
+
+func (g *generator) method(m method) {
+	var args []string
+	var query []parameter
+	body := "nil"
+	for _, p := range m.operation.Parameters {
+		switch p.In {
+		case "path":
+			args = append(args, fmt.Sprintf("%v string", unexported(p.Name)))
+		case "body":
+			args = append(args, "body "+g.goType(p.Schema))
+			body = "body"
+		case "query":
+			query = append(query, p)
+		}
+	}
+
+	params := "nil"
+	if len(query) > 0 {
+		paramsType := m.name + "Params"
+		g.printf("type %v struct {\n", paramsType)
+		for _, p := range query {
+			g.printf("%v %v\n", exported(p.Name), g.goType(&schema{Type: p.Type}))
+		}
+		g.printf("}\n\n")
+
+		g.printf("func (p %v) values() url.Values {\nvalues := url.Values{}\n", paramsType)
+		for _, p := range query {
+			field := "p." + exported(p.Name)
+			switch p.Type {
+			case "integer":
+				g.printf("if %v != 0 {\nvalues.Set(%q, strconv.Itoa(%v))\n}\n", field, p.Name, field)
+			case "number":
+				g.printf("if %v != 0 {\nvalues.Set(%q, strconv.FormatFloat(%v, 'f', -1, 64))\n}\n", field, p.Name, field)
+			case "boolean":
+				g.printf("if %v {\nvalues.Set(%q, \"true\")\n}\n", field, p.Name)
+			default:
+				g.printf("if %v != \"\" {\nvalues.Set(%q, %v)\n}\n", field, p.Name, field)
+			}
+		}
+		g.printf("return values\n}\n\n")
+
+		args = append(args, "params "+paramsType)
+		params = "params.values()"
+	}
+
+	path := pathParam.ReplaceAllStringFunc(m.path, func(param string) string {
+		return `" + url.PathEscape(` + unexported(strings.Trim(param, "{}")) + `) + "`
+	})
+	path = strings.TrimSuffix(`"`+path+`"`, ` + ""`)
+
+	result := g.result(m.operation)
+	comment(&g.buf, fmt.Sprintf("%v calls %v %v.\n%v.", m.name, m.httpName, m.path, m.operation.Summary))
+	if result == "" {
+		g.printf("func (c *Client) %v(%v) error {\n", m.name, strings.Join(append([]string{"ctx context.Context"}, args...), ", "))
+		g.printf("return c.do(ctx, %q, %v, %v, %v, nil)\n}\n\n", m.httpName, path, params, body)
+		return
+	}
+
+	g.printf("func (c *Client) %v(%v) (%v, error) {\n", m.name, strings.Join(append([]string{"ctx context.Context"}, args...), ", "), result)
+	if strings.HasPrefix(result, "*") {
+		g.printf("var out %v\n", strings.TrimPrefix(result, "*"))
+		g.printf("err := c.do(ctx, %q, %v, %v, %v, &out)\n", m.httpName, path, params, body)
+		g.printf("if err != nil {\nreturn nil, err\n}\nreturn &out, nil\n}\n\n")
+	} else {
+		g.printf("var out %v\n", result)
+		g.printf("err := c.do(ctx, %q, %v, %v, %v, &out)\n", m.httpName, path, params, body)
+		g.printf("return out, err\n}\n\n")
+	}
+}
+
+// result is the type of the first successful response, objects are returned by pointer.
+func (g *generator) result(o operation) string {
+	for _, code := range sortedKeys(o.Responses) {
+		if !strings.HasPrefix(code, "2") || o.Responses[code].Schema == nil {
+			continue
+		}
+		s := o.Responses[code].Schema
+		if s.Ref != "" {
+			return "*" + g.goType(s)
+		}
+		return g.goType(s)
+	}
+	return ""
+}
+
+// operationName joins the method and the static path segments without the version, a trailing parameter
+// adds By, GET /v1/profile/moods/{id} is GetProfileMoodsById.
+func operationName(httpMethod string, path string) string {
+	name := exported(strings.ToLower(httpMethod))
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range segments {
+		if versionSegment.MatchString(segment) {
+			continue
+		}
+		if strings.HasPrefix(segment, "{") {
+			if i == len(segments)-1 {
+				name += "By" + exported(strings.Trim(segment, "{}"))
+			}
+			continue
+		}
+		name += exported(segment)
+	}
+	return name
+}
+
+// exported turns a json or path name into an exported Go name, createdAt is CreatedAt, thera-chat is TheraChat.
+func exported(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func unexported(name string) string {
+	name = exported(name)
+	if name == "" {
+		return name
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+func comment(buf *bytes.Buffer, text string) {
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		if line != "" {
+			fmt.Fprintf(buf, "// %v\n", line)
+		}
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClientIsGenerated(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "client_gen.go")
+	err := run(1, output)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	generated, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	committed, err := os.ReadFile(filepath.Join("..", "client_gen.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(generated, committed) {
+		t.Error("client/client_gen.go is stale, run go generate ./... in client")
+	}
+}
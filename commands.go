@@ -0,0 +1,100 @@
+package main
+
+import (
+	"chatgpt/api/contract"
+	h "chatgpt/api/handler"
+	"chatgpt/config"
+	s "chatgpt/server"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"os"
+	"slices"
+	"strings"
+)
+
+// configCommand runs `config print [--redacted] [config flags]`, it prints the merged configuration
+// as JSON and its validation errors, and returns the exit code.
+func configCommand(args []string) int {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintln(os.Stderr, "usage: thera-chat config print [--redacted] [config flags]")
+		return 2
+	}
+
+	fs := flag.NewFlagSet("config print", flag.ContinueOnError)
+	redacted := fs.Bool("redacted", false, "hide secrets")
+
+	configuration, err := config.Load(fs, args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	out := *configuration
+	if *redacted {
+		out = out.Redacted()
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(out)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	err = configuration.Validate()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// contractCommand runs `contract`, it registers the routes without connecting anywhere and compares the routes
+// of every version with its swagger spec, the exit code is 1 if they differ.
+func contractCommand() int {
+	gin.SetMode(gin.ReleaseMode)
+
+	configuration := config.Defaults()
+	server := s.NewApiServer(&configuration, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	server.Init(context.Background())
+	h.NewHandler(server).InitRoutes()
+	routes := server.Router.Routes()
+
+	var problems []string
+	for _, version := range s.Versions {
+		prefix := s.VersionPrefix(version)
+
+		var versionRoutes gin.RoutesInfo
+		for _, route := range routes {
+			if strings.HasPrefix(route.Path, prefix+"/") || slices.Contains(s.Unversioned, route.Path) {
+				versionRoutes = append(versionRoutes, route)
+			}
+		}
+
+		doc, err := s.Spec(version)
+		if err == nil {
+			var versionProblems []string
+			versionProblems, err = contract.Check(doc, versionRoutes)
+			problems = append(problems, versionProblems...)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	problems = append(problems, contract.CheckAliases(routes, s.VersionPrefix(s.DefaultVersion), s.Unversioned)...)
+
+	for _, problem := range problems {
+		fmt.Fprintln(os.Stderr, problem)
+	}
+	if len(problems) > 0 {
+		return 1
+	}
+
+	fmt.Println("spec matches the routes")
+	return 0
+}
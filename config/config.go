@@ -1,10 +1,7 @@
 package config
 
 import (
-	"encoding/json"
-	"io"
-	"log"
-	"os"
+	"chatgpt/models"
 )
 
 type Config struct {
@@ -13,43 +10,134 @@ type Config struct {
 	DbHost            string `json:"dbHost"`
 	DbUser            string `json:"dbUser"`
 	DbName            string `json:"dbName"`
-	DbPass            string `json:"dbPass"`
+	DbPass            string `json:"dbPass" secret:"true"`
 	DbPort            int    `json:"dbPort"`
 	DbMode            string `json:"dbMode"`
 	DbLogMode         bool   `json:"dbLogMode"`
+	LogLevel          string `json:"logLevel"`
+	LogFormat         string `json:"logFormat"`
 	CacheHost         string `json:"cacheHost"`
-	CachePass         string `json:"cachePass"`
-	SecretKeyAccess   string `json:"secretKeyAccess"`
-	SecretKeyRefresh  string `json:"secretKeyRefresh"`
-	OpenAiAuthToken   string `json:"openAiAuthToken"`
+	CachePass         string `json:"cachePass" secret:"true"`
+	SecretKeyAccess   string `json:"secretKeyAccess" secret:"true"`
+	SecretKeyRefresh  string `json:"secretKeyRefresh" secret:"true"`
+	OpenAiAuthToken   string `json:"openAiAuthToken" secret:"true"`
 	OpenAiAssistantId string `json:"openAiAssistantId"`
 
+	// Personas are merged with the personas table, the table wins on the same id.
+	// Personas, DefaultPersona and CorsOrigins are reloaded on SIGHUP and can be replaced through /admin/settings.
+	Personas       []models.Persona `json:"personas"`
+	DefaultPersona string           `json:"defaultPersona"`
+	// CorsOrigins allowed to call the API from browsers, none if empty and all of them with "*".
+	// The profile of the environment fills them if unset.
+	CorsOrigins []string `json:"corsOrigins"`
+
+	// TLS is served on Port with the certificate files, reloaded when they change, or with Let's Encrypt
+	// certificates of TlsAutocertDomains cached in TlsAutocertDir. Plain HTTP if neither is set.
+	TlsCertFile        string   `json:"tlsCertFile"`
+	TlsKeyFile         string   `json:"tlsKeyFile"`
+	TlsAutocertDomains []string `json:"tlsAutocertDomains"`
+	TlsAutocertDir     string   `json:"tlsAutocertDir"`
+	TlsAutocertEmail   string   `json:"tlsAutocertEmail"`
+	// Port of a plain HTTP listener redirecting to HTTPS and answering ACME challenges, disabled if 0.
+	HttpRedirectPort int `json:"httpRedirectPort"`
+	// H2c serves HTTP/2 over plain HTTP, for internal deployments behind proxies speaking cleartext HTTP/2.
+	H2c bool `json:"h2c"`
+
+	// Routes without a version prefix are deprecated aliases of /v1. The dates, like 2025-12-31, are sent
+	// in the Deprecation and Sunset headers, Deprecation is true if its date is unset.
+	UnversionedDeprecatedAt string `json:"unversionedDeprecatedAt"`
+	UnversionedSunsetAt     string `json:"unversionedSunsetAt"`
+
+	// Environment selects the profile filling the CORS and security settings left unset: development, staging or production.
+	Environment       string   `json:"environment"`
+	CorsMethods       []string `json:"corsMethods"`
+	CorsHeaders       []string `json:"corsHeaders"`
+	CorsExposeHeaders []string `json:"corsExposeHeaders"`
+	// Browsers send cookies to the listed origins only, it can't be combined with the "*" origin.
+	CorsAllowCredentials bool `json:"corsAllowCredentials"`
+	CorsMaxAgeSeconds    int  `json:"corsMaxAgeSeconds"`
+	// Strict-Transport-Security max age of HTTPS responses, negative disables it, the profile sets it if 0.
+	HstsMaxAgeSeconds int `json:"hstsMaxAgeSeconds"`
+	// X-Frame-Options of all responses: DENY or SAMEORIGIN.
+	FrameOptions string `json:"frameOptions"`
+	// Largest body of chat requests in bytes, larger ones fail with 413.
+	ChatBodyLimitBytes int `json:"chatBodyLimitBytes"`
+	// Responses of chat and auth POSTs with an Idempotency-Key header are replayed to retries for this long.
+	IdempotencyTtlHours int `json:"idempotencyTtlHours"`
+
+	// Conversation is summarized after this many new messages, 0 disables the memory.
+	MemorySummaryEvery int    `json:"memorySummaryEvery"`
+	MemoryModel        string `json:"memoryModel"`
+
 	GoogleAuthAudiences []string `json:"googleAuthAudiences"`
 
 	AppleAuthAndroidClientId string `json:"appleAuthAndroidClientId"`
 	AppleAuthClientId        string `json:"appleAuthClientId"`
-	AppleAuthPrivateKey      string `json:"appleAuthPrivateKey"`
+	AppleAuthPrivateKey      string `json:"appleAuthPrivateKey" secret:"true"`
 	AppleAuthTeamId          string `json:"appleAuthTeamId"`
 	AppleAuthKeyId           string `json:"appleAuthKeyId"`
-}
 
-func NewConfiguration() *Config {
-	//var cfg config
-	conf, err := os.Open("./config.json")
-	if err != nil {
-		log.Fatal(err)
-		return nil
-	}
-	defer conf.Close()
+	// Hours between a deletion request and the actual purge of the account, logging in meanwhile cancels it.
+	AccountDeletionGraceHours int `json:"accountDeletionGraceHours"`
 
-	byteValue, _ := io.ReadAll(conf)
+	// Channels of reminder notifications: log, webhook, push.
+	Notifiers           []string `json:"notifiers"`
+	NotifyWebhookUrl    string   `json:"notifyWebhookUrl"`
+	NotifyWebhookSecret string   `json:"notifyWebhookSecret" secret:"true"`
 
-	var config Config
-	err = json.Unmarshal(byteValue, &config)
-	if err != nil {
-		log.Fatal(err)
-		return nil
+	// Push sender: fcm, log to only log pushes, the default, or fake to record them in memory.
+	PushSender string `json:"pushSender"`
+	// Service account of Firebase authentication and FCM pushes.
+	FirebaseCredentialsPath string `json:"firebaseCredentialsPath"`
+
+	// Metrics are served on this address instead of the API port if set, e.g. ":9090".
+	MetricsAddr string `json:"metricsAddr"`
+	// Bearer token required to read the metrics, open if empty.
+	MetricsToken string `json:"metricsToken" secret:"true"`
+
+	// Readiness also probes OpenAI, the result is reused for this many seconds, 60 by default.
+	ReadyOpenAiProbe        bool `json:"readyOpenAiProbe"`
+	ReadyOpenAiProbeSeconds int  `json:"readyOpenAiProbeSeconds"`
+
+	// Seconds in-flight requests get to finish on shutdown before they are cancelled, 30 by default.
+	ShutdownDrainSeconds int `json:"shutdownDrainSeconds"`
+	// Seconds to keep serving with failing readiness before the listener closes.
+	ShutdownDelaySeconds int `json:"shutdownDelaySeconds"`
+
+	// Span exporter: otlp, or none to disable tracing. Trace context is propagated either way.
+	TraceExporter string `json:"traceExporter"`
+	// OTLP/HTTP collector host and port, the OTEL_EXPORTER_OTLP_* variables apply if empty.
+	TraceEndpoint    string `json:"traceEndpoint"`
+	TraceInsecure    bool   `json:"traceInsecure"`
+	TraceServiceName string `json:"traceServiceName"`
+	// Share of new traces to sample, all of them if 0.
+	TraceSampleRatio float64 `json:"traceSampleRatio"`
+}
+
+// Defaults are the values of settings missing in every layer.
+func Defaults() Config {
+	return Config{
+		Port:                      8080,
+		DbPort:                    5432,
+		DbMode:                    "disable",
+		LogLevel:                  "info",
+		LogFormat:                 "json",
+		CacheHost:                 "localhost:6379",
+		FirebaseCredentialsPath:   "./thera-chat-firebase.json",
+		ShutdownDrainSeconds:      30,
+		TlsAutocertDir:            "./autocert",
+		Environment:               EnvironmentProduction,
+		CorsMethods:               []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD"},
+		CorsHeaders:               []string{"Origin", "Content-Length", "Content-Type", "Authorization", "Accept-Language"},
+		CorsMaxAgeSeconds:         12 * 60 * 60,
+		FrameOptions:              "DENY",
+		ChatBodyLimitBytes:        64 << 10,
+		IdempotencyTtlHours:       24,
+		AccountDeletionGraceHours: 30 * 24,
 	}
+}
 
-	return &config
+// TLS tells if the API is served over HTTPS.
+func (c *Config) TLS() bool {
+	return c.TlsCertFile != "" || len(c.TlsAutocertDomains) > 0
 }
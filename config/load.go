@@ -0,0 +1,226 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	EnvPrefix = "THERA_"
+	// EnvConfigFile and the -config flag point to the config file.
+	EnvConfigFile = EnvPrefix + "CONFIG"
+	// FileSuffix of a variable reads the value from the file it names, e.g. THERA_DB_PASS_FILE.
+	FileSuffix = "_FILE"
+
+	DefaultFile = "./config.json"
+
+	Redacted = "[redacted]"
+
+	// DateLayout of the dates in the configuration.
+	DateLayout = "2006-01-02"
+)
+
+// setting is a scalar field of Config, settable from the environment and flags.
+type setting struct {
+	index  int
+	name   string
+	env    string
+	flag   string
+	secret bool
+}
+
+// settings are built once from the json tags of Config.
+var settings = func() []setting {
+	var result []setting
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !settable(field.Type) {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		words := splitWords(name)
+		result = append(result, setting{
+			index:  i,
+			name:   name,
+			env:    EnvPrefix + strings.ToUpper(strings.Join(words, "_")),
+			flag:   strings.Join(words, "-"),
+			secret: field.Tag.Get("secret") == "true",
+		})
+	}
+	return result
+}()
+
+func settable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Int, reflect.Bool, reflect.Float64:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.String
+	}
+	return false
+}
+
+// splitWords splits a camelCase name into lower case words, openAiAuthToken is open, ai, auth, token.
+func splitWords(name string) []string {
+	var words []string
+	start := 0
+	for i, r := range name {
+		if i > 0 && unicode.IsUpper(r) {
+			words = append(words, strings.ToLower(name[start:i]))
+			start = i
+		}
+	}
+	return append(words, strings.ToLower(name[start:]))
+}
+
+// Load builds the configuration from the defaults, the config file, THERA_* environment variables
+// and the flags, each layer overrides the previous ones, then the profile of the environment fills
+// the unset settings. The flags are registered on fs, so callers can add their own before, and
+// parsed from args.
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	path := fs.String("config", "", "config file, "+EnvConfigFile+" or "+DefaultFile+" if empty")
+	values := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		values[s.name] = fs.String(s.flag, "", s.name+", also "+s.env)
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	config := Defaults()
+
+	file, required := *path, true
+	if file == "" {
+		file = os.Getenv(EnvConfigFile)
+	}
+	if file == "" {
+		file, required = DefaultFile, false
+	}
+
+	err = config.readFile(file, required)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for _, s := range settings {
+		value, ok, err := lookupEnv(s.env)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if ok {
+			errs = append(errs, config.set(s, value, s.env))
+		}
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag == f.Name {
+				errs = append(errs, config.set(s, *values[s.name], "-"+s.flag))
+			}
+		}
+	})
+
+	err = errors.Join(errs...)
+	if err != nil {
+		return nil, err
+	}
+
+	config.applyProfile()
+	return &config, nil
+}
+
+func (c *Config) readFile(path string, required bool) error {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+
+	err = json.Unmarshal(content, c)
+	if err != nil {
+		return fmt.Errorf("config file %v: %w", path, err)
+	}
+	return nil
+}
+
+// lookupEnv reads the variable, or the file named by the variable with the _FILE suffix.
+func lookupEnv(name string) (string, bool, error) {
+	if value, ok := os.LookupEnv(name); ok {
+		return value, true, nil
+	}
+
+	path, ok := os.LookupEnv(name + FileSuffix)
+	if !ok {
+		return "", false, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("%v: %w", name+FileSuffix, err)
+	}
+	return strings.TrimRight(string(content), "\r\n"), true, nil
+}
+
+// set parses the value into the field, source names the variable or flag in errors.
+func (c *Config) set(s setting, value string, source string) error {
+	field := reflect.ValueOf(c).Elem().Field(s.index)
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%v: %v must be an integer, got %q", source, s.name, value)
+		}
+		field.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%v: %v must be true or false, got %q", source, s.name, value)
+		}
+		field.SetBool(b)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%v: %v must be a number, got %q", source, s.name, value)
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	}
+	return nil
+}
+
+// Redacted returns a copy with the secrets replaced, safe to print or log.
+func (c Config) Redacted() Config {
+	value := reflect.ValueOf(&c).Elem()
+	for _, s := range settings {
+		field := value.Field(s.index)
+		if s.secret && field.String() != "" {
+			field.SetString(Redacted)
+		}
+	}
+	return c
+}
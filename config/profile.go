@@ -0,0 +1,38 @@
+package config
+
+const (
+	EnvironmentDevelopment = "development"
+	EnvironmentStaging     = "staging"
+	EnvironmentProduction  = "production"
+)
+
+// profile holds the defaults of an environment. Unlike Defaults they depend on the environment
+// set by any layer, so they are applied after all layers to the settings still unset.
+type profile struct {
+	corsOrigins       []string
+	hstsMaxAgeSeconds int
+}
+
+var profiles = map[string]profile{
+	// Local web clients run on any port, the API is served over plain HTTP.
+	EnvironmentDevelopment: {corsOrigins: []string{"*"}},
+	// A short max age, so a staging host can go back to HTTP.
+	EnvironmentStaging:    {hstsMaxAgeSeconds: 24 * 60 * 60},
+	EnvironmentProduction: {hstsMaxAgeSeconds: 365 * 24 * 60 * 60},
+}
+
+// applyProfile fills the settings left unset with the defaults of the environment.
+func (c *Config) applyProfile() {
+	p, ok := profiles[c.Environment]
+	if !ok {
+		// Validate reports the environment
+		return
+	}
+
+	if c.CorsOrigins == nil {
+		c.CorsOrigins = p.corsOrigins
+	}
+	if c.HstsMaxAgeSeconds == 0 {
+		c.HstsMaxAgeSeconds = p.hstsMaxAgeSeconds
+	}
+}
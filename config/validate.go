@@ -0,0 +1,127 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Validate reports every invalid setting at once, each error names the setting.
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(name string, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%v: "+format, append([]any{name}, args...)...))
+	}
+	oneOf := func(name string, value string, allowed ...string) {
+		if !slices.Contains(allowed, value) {
+			invalid(name, "must be one of %q, got %q", allowed, value)
+		}
+	}
+
+	required := map[string]string{
+		"dbHost":            c.DbHost,
+		"dbUser":            c.DbUser,
+		"dbName":            c.DbName,
+		"cacheHost":         c.CacheHost,
+		"secretKeyAccess":   c.SecretKeyAccess,
+		"secretKeyRefresh":  c.SecretKeyRefresh,
+		"openAiAuthToken":   c.OpenAiAuthToken,
+		"openAiAssistantId": c.OpenAiAssistantId,
+		// Firebase authentication is always on, not only for FCM pushes.
+		"firebaseCredentialsPath": c.FirebaseCredentialsPath,
+	}
+	for _, s := range settings {
+		if value, ok := required[s.name]; ok && value == "" {
+			invalid(s.name, "is required, set it in the config file, %v or -%v", s.env, s.flag)
+		}
+	}
+
+	if c.Port <= 0 || c.Port > 65535 {
+		invalid("port", "must be between 1 and 65535, got %v", c.Port)
+	}
+	if c.DbPort <= 0 || c.DbPort > 65535 {
+		invalid("dbPort", "must be between 1 and 65535, got %v", c.DbPort)
+	}
+
+	if (c.TlsCertFile == "") != (c.TlsKeyFile == "") {
+		invalid("tlsKeyFile", "and tlsCertFile must be set together")
+	}
+	if c.TlsCertFile != "" && len(c.TlsAutocertDomains) > 0 {
+		invalid("tlsAutocertDomains", "can't be combined with tlsCertFile")
+	}
+	if len(c.TlsAutocertDomains) > 0 && c.TlsAutocertDir == "" {
+		invalid("tlsAutocertDir", "is required by tlsAutocertDomains")
+	}
+	if c.HttpRedirectPort != 0 {
+		if c.HttpRedirectPort < 0 || c.HttpRedirectPort > 65535 || c.HttpRedirectPort == c.Port {
+			invalid("httpRedirectPort", "must be between 1 and 65535 and differ from port, got %v", c.HttpRedirectPort)
+		}
+		if !c.TLS() {
+			invalid("httpRedirectPort", "requires tlsCertFile or tlsAutocertDomains")
+		}
+	}
+	if c.H2c && c.TLS() {
+		invalid("h2c", "is for plain HTTP only, HTTP/2 is negotiated over TLS anyway")
+	}
+
+	oneOf("dbMode", c.DbMode, "disable", "allow", "prefer", "require", "verify-ca", "verify-full")
+	oneOf("logLevel", c.LogLevel, "debug", "info", "warn", "error")
+	oneOf("logFormat", c.LogFormat, "json", "text")
+	oneOf("environment", c.Environment, EnvironmentDevelopment, EnvironmentStaging, EnvironmentProduction)
+	oneOf("frameOptions", c.FrameOptions, "DENY", "SAMEORIGIN")
+	oneOf("pushSender", c.PushSender, "", "log", "fake", "fcm")
+	oneOf("traceExporter", c.TraceExporter, "", "none", "otlp")
+	for _, notifier := range c.Notifiers {
+		oneOf("notifiers", notifier, "log", "webhook", "push")
+	}
+
+	if slices.Contains(c.Notifiers, "webhook") && c.NotifyWebhookUrl == "" {
+		invalid("notifyWebhookUrl", "is required by the webhook notifier")
+	}
+
+	if c.CorsAllowCredentials && slices.Contains(c.CorsOrigins, "*") {
+		invalid("corsAllowCredentials", "can't be combined with the \"*\" origin, list the origins in corsOrigins")
+	}
+	if c.CorsMaxAgeSeconds < 0 {
+		invalid("corsMaxAgeSeconds", "must not be negative, got %v", c.CorsMaxAgeSeconds)
+	}
+	if c.ChatBodyLimitBytes <= 0 {
+		invalid("chatBodyLimitBytes", "must be positive, got %v", c.ChatBodyLimitBytes)
+	}
+	for _, p := range c.Personas {
+		if p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > 2) {
+			invalid("personas", "temperature of %v must be from 0 to 2, got %v", p.Id, *p.Temperature)
+		}
+	}
+	if c.IdempotencyTtlHours <= 0 {
+		invalid("idempotencyTtlHours", "must be positive, got %v", c.IdempotencyTtlHours)
+	}
+
+	for name, date := range map[string]string{
+		"unversionedDeprecatedAt": c.UnversionedDeprecatedAt,
+		"unversionedSunsetAt":     c.UnversionedSunsetAt,
+	} {
+		if _, err := time.Parse(DateLayout, date); date != "" && err != nil {
+			invalid(name, "must be a date like 2025-12-31, got %q", date)
+		}
+	}
+
+	if c.MemorySummaryEvery < 0 {
+		invalid("memorySummaryEvery", "must not be negative, got %v", c.MemorySummaryEvery)
+	}
+	if c.AccountDeletionGraceHours < 0 {
+		invalid("accountDeletionGraceHours", "must not be negative, got %v", c.AccountDeletionGraceHours)
+	}
+	if c.ShutdownDrainSeconds < 0 {
+		invalid("shutdownDrainSeconds", "must not be negative, got %v", c.ShutdownDrainSeconds)
+	}
+	if c.ShutdownDelaySeconds < 0 {
+		invalid("shutdownDelaySeconds", "must not be negative, got %v", c.ShutdownDelaySeconds)
+	}
+	if c.TraceSampleRatio < 0 || c.TraceSampleRatio > 1 {
+		invalid("traceSampleRatio", "must be between 0 and 1, got %v", c.TraceSampleRatio)
+	}
+
+	return errors.Join(errs...)
+}
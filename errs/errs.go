@@ -0,0 +1,147 @@
+package errs
+
+import (
+	"chatgpt/models"
+	"errors"
+	"github.com/sashabaranov/go-openai"
+	"net/http"
+)
+
+// Code is the stable machine readable kind of an error, clients may switch on it.
+type Code string
+
+const (
+	CodeBadRequest    Code = "bad_request"
+	CodeValidation    Code = "validation"
+	CodeUnauthorized  Code = "unauthorized"
+	CodeForbidden     Code = "forbidden"
+	CodeNotFound      Code = "not_found"
+	CodeConflict      Code = "conflict"
+	CodeUnprocessable Code = "unprocessable"
+	CodeTooLarge      Code = "too_large"
+	CodeUpstream      Code = "upstream"
+	CodeUnavailable   Code = "unavailable"
+	CodeInternal      Code = "internal"
+)
+
+var statuses = map[Code]int{
+	CodeBadRequest:    http.StatusBadRequest,
+	CodeValidation:    http.StatusBadRequest,
+	CodeUnauthorized:  http.StatusUnauthorized,
+	CodeForbidden:     http.StatusForbidden,
+	CodeNotFound:      http.StatusNotFound,
+	CodeConflict:      http.StatusConflict,
+	CodeUnprocessable: http.StatusUnprocessableEntity,
+	CodeTooLarge:      http.StatusRequestEntityTooLarge,
+	CodeUpstream:      http.StatusBadGateway,
+	CodeUnavailable:   http.StatusServiceUnavailable,
+	CodeInternal:      http.StatusInternalServerError,
+}
+
+// Status is the HTTP status of the code.
+func (c Code) Status() int {
+	if status, ok := statuses[c]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Error is an API error. Message is safe to show to clients and Key selects its
+// translation, the cause is only logged.
+type Error struct {
+	Code    Code
+	Key     string
+	Message string
+	Err     error
+}
+
+func New(code Code, key string, message string) *Error {
+	return &Error{Code: code, Key: key, Message: message}
+}
+
+// Wrap keeps err as the cause of the public error.
+func Wrap(err error, code Code, key string, message string) *Error {
+	return &Error{Code: code, Key: key, Message: message, Err: err}
+}
+
+// Internal hides err behind the generic internal error.
+func Internal(err error) *Error {
+	return Wrap(err, CodeInternal, string(CodeInternal), "internal server error")
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches errors of the same code and key, so sentinels match their wrapped copies.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code && t.Key == e.Key
+}
+
+var (
+	ErrUnauthorized = New(CodeUnauthorized, "unauthorized", "not authorized")
+	ErrForbidden    = New(CodeForbidden, "forbidden", "forbidden")
+	ErrNoToken      = New(CodeUnauthorized, "token_missing", "no Authorization token")
+	ErrInvalidToken = New(CodeUnauthorized, "token_invalid", "invalid or expired token")
+)
+
+// From converts any error aborted with the status into an API error.
+// Errors that are not typed keep their text only as the cause.
+func From(err error, status int) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+
+	var advanced models.AdvancedErrorResponse
+	if errors.As(err, &advanced) {
+		code := codeOf(advanced.Code)
+		if code == CodeBadRequest {
+			code = CodeValidation
+		}
+		return Wrap(err, code, advanced.Key, advanced.Message)
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return Wrap(err, CodeTooLarge, string(CodeTooLarge), http.StatusText(http.StatusRequestEntityTooLarge))
+	}
+
+	var response models.ErrorResponse
+	if errors.As(err, &response) {
+		status = response.Code
+	}
+
+	if models.IsErrNotFound(err) && status >= http.StatusInternalServerError {
+		return Wrap(err, CodeNotFound, string(CodeNotFound), "not found")
+	}
+
+	var apiErr *openai.APIError
+	var requestErr *openai.RequestError
+	if errors.As(err, &apiErr) || errors.As(err, &requestErr) {
+		return Wrap(err, CodeUpstream, string(CodeUpstream), "assistant is unavailable")
+	}
+
+	code := codeOf(status)
+	return Wrap(err, code, string(code), http.StatusText(code.Status()))
+}
+
+func codeOf(status int) Code {
+	for code, s := range statuses {
+		if s == status && code != CodeValidation {
+			return code
+		}
+	}
+	if status >= http.StatusInternalServerError {
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
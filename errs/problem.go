@@ -0,0 +1,44 @@
+package errs
+
+import (
+	"chatgpt/i18n"
+	"net/http"
+)
+
+const (
+	ProblemContentType = "application/problem+json"
+	// problemType tells that the problem has no documentation page, Code tells the kind.
+	problemType = "about:blank"
+)
+
+// Problem is the RFC 7807 body of every error response.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail"`
+	Instance string `json:"instance,omitempty"`
+	// Code is stable between releases, Key names the failed field or the exact reason.
+	Code Code   `json:"code"`
+	Key  string `json:"key,omitempty"`
+}
+
+// Problem renders the error with the detail translated to the locale.
+func (e *Error) Problem(locale string, instance string) Problem {
+	status := e.Code.Status()
+
+	key := e.Key
+	if key == string(e.Code) {
+		key = ""
+	}
+
+	return Problem{
+		Type:     problemType,
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   i18n.Message(locale, e.Key, e.Message),
+		Instance: instance,
+		Code:     e.Code,
+		Key:      key,
+	}
+}
@@ -0,0 +1,111 @@
+package flags
+
+import (
+	"chatgpt/models"
+	"context"
+	"hash/fnv"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// cacheTTL bounds how long other replicas use a flag after an admin changed it.
+const cacheTTL = 30 * time.Second
+
+// Subject is who a flag is evaluated for, a user or an anonymous session.
+type Subject struct {
+	Id    string
+	Roles []string
+}
+
+type snapshot struct {
+	flags    map[string]models.FeatureFlag
+	loadedAt time.Time
+}
+
+// Service evaluates feature flags stored in Postgres. All flags are kept in memory and reloaded
+// after cacheTTL, if the reload fails the previous flags stay in use.
+type Service struct {
+	Db models.DbClient
+
+	mu       sync.Mutex
+	snapshot atomic.Pointer[snapshot]
+}
+
+func New(db models.DbClient) *Service {
+	s := &Service{Db: db}
+	s.snapshot.Store(&snapshot{})
+	return s
+}
+
+// Enabled tells if the flag is on for the subject, unknown flags are off.
+func (s *Service) Enabled(ctx context.Context, key string, subject Subject) bool {
+	flag, ok := s.flags(ctx)[key]
+	return ok && Evaluate(flag, subject)
+}
+
+// Evaluate tells if the flag is on for the subject. The percentage rollout hashes the flag key
+// with the subject, so a subject keeps its answer while the percentage grows and flags roll out independently.
+func Evaluate(flag models.FeatureFlag, subject Subject) bool {
+	if !flag.Enabled {
+		return false
+	}
+	if subject.Id != "" && slices.Contains(flag.Users, subject.Id) {
+		return true
+	}
+	for _, role := range subject.Roles {
+		if slices.Contains(flag.Roles, role) {
+			return true
+		}
+	}
+
+	if flag.Percentage >= 100 {
+		return true
+	}
+	if flag.Percentage <= 0 || subject.Id == "" {
+		return false
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(flag.Key + ":" + subject.Id))
+	return int(hash.Sum32()%100) < flag.Percentage
+}
+
+// Invalidate drops the cached flags, the next evaluation reloads them.
+func (s *Service) Invalidate() {
+	s.snapshot.Store(&snapshot{})
+}
+
+func (s *Service) flags(ctx context.Context) map[string]models.FeatureFlag {
+	current := s.snapshot.Load()
+	if time.Since(current.loadedAt) < cacheTTL {
+		return current.flags
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// another request may have reloaded them meanwhile
+	current = s.snapshot.Load()
+	if time.Since(current.loadedAt) < cacheTTL {
+		return current.flags
+	}
+
+	var stored []models.FeatureFlag
+	err := s.Db.Get(ctx, models.FilterParams{}, &stored)
+	if models.AllowErrNotFound(err) != nil {
+		slog.ErrorContext(ctx, "load feature flags", "error", err)
+		// retry after the TTL instead of on every request
+		s.snapshot.Store(&snapshot{flags: current.flags, loadedAt: time.Now()})
+		return current.flags
+	}
+
+	flags := make(map[string]models.FeatureFlag, len(stored))
+	for _, flag := range stored {
+		flags[flag.Key] = flag
+	}
+	s.snapshot.Store(&snapshot{flags: flags, loadedAt: time.Now()})
+	return flags
+}
@@ -0,0 +1,75 @@
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"path"
+	"strings"
+)
+
+const (
+	Ru      = "ru"
+	En      = "en"
+	Default = Ru
+)
+
+// Languages are the names of supported locales in the assistant instructions.
+var Languages = map[string]string{
+	Ru: "Russian",
+	En: "English",
+}
+
+//go:embed catalogs/*.json
+var files embed.FS
+
+// catalogs are messages keyed by AdvancedErrorResponse.Key per locale.
+var catalogs = load()
+
+func load() map[string]map[string]string {
+	catalogs := make(map[string]map[string]string)
+	for locale := range Languages {
+		data, err := files.ReadFile(path.Join("catalogs", locale+".json"))
+		if err != nil {
+			panic(err)
+		}
+
+		var catalog map[string]string
+		err = json.Unmarshal(data, &catalog)
+		if err != nil {
+			panic(err)
+		}
+		catalogs[locale] = catalog
+	}
+	return catalogs
+}
+
+// Supported reports whether the locale has a catalog.
+func Supported(locale string) bool {
+	_, ok := catalogs[locale]
+	return ok
+}
+
+// Negotiate picks the preferred locale if supported,
+// else the first supported language of an Accept-Language value.
+func Negotiate(preferred string, acceptLanguage string) string {
+	if Supported(preferred) {
+		return preferred
+	}
+
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag := strings.TrimSpace(strings.SplitN(part, ";", 2)[0])
+		lang := strings.ToLower(strings.SplitN(tag, "-", 2)[0])
+		if Supported(lang) {
+			return lang
+		}
+	}
+	return Default
+}
+
+// Message returns the localized message of the key, the fallback if the catalog has none.
+func Message(locale string, key string, fallback string) string {
+	if msg, ok := catalogs[locale][key]; ok {
+		return msg
+	}
+	return fallback
+}
@@ -0,0 +1,127 @@
+package jobs
+
+import (
+	"chatgpt/ai"
+	"chatgpt/auth"
+	"chatgpt/models"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const deletionInterval = 10 * time.Minute
+
+// AccountDeletion purges accounts whose deletion grace period is over.
+type AccountDeletion struct {
+	Db    models.DbClient
+	Cache models.CacheClient
+	AI    *ai.AI
+}
+
+func NewAccountDeletion(db models.DbClient, cache models.CacheClient, ai *ai.AI) *AccountDeletion {
+	return &AccountDeletion{
+		Db:    db,
+		Cache: cache,
+		AI:    ai,
+	}
+}
+
+// Run checks for due accounts until ctx is cancelled.
+func (j *AccountDeletion) Run(ctx context.Context) {
+	ticker := time.NewTicker(deletionInterval)
+	defer ticker.Stop()
+
+	for {
+		j.purgeDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (j *AccountDeletion) purgeDue(ctx context.Context) {
+	var filter models.FilterParams
+	filter.Filter = fmt.Sprintf(`delete_at <= '%v'`, time.Now().UTC().Format(time.RFC3339))
+
+	var users []models.User
+	err := j.Db.Get(ctx, filter, &users)
+	if models.AllowErrNotFound(err) != nil {
+		slog.ErrorContext(ctx, "due account deletions", "error", err)
+		return
+	}
+
+	for _, user := range users {
+		err = j.Purge(ctx, user)
+		if err != nil {
+			slog.ErrorContext(ctx, "account deletion", "user", user.Id, "error", err)
+		}
+	}
+}
+
+// Purge removes the user's OpenAI threads, stored history, cached data, sessions and the user row.
+func (j *AccountDeletion) Purge(ctx context.Context, user models.User) error {
+	userId := user.Id.String()
+
+	conversations, err := models.UserConversations(ctx, j.Db, user)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range conversations {
+		conversation, err := models.GetConversation(ctx, j.Db, id, user.Id)
+		if err != nil {
+			return err
+		}
+
+		threads := []string{id}
+		if conversation.Thread != "" {
+			threads = append(threads, conversation.Thread)
+		}
+		for _, thread := range threads {
+			err = j.AI.DeleteThread(ctx, thread)
+			if err != nil && !strings.Contains(err.Error(), "status code: 404") {
+				return err
+			}
+		}
+	}
+
+	err = auth.RevokeUserTokens(ctx, j.Cache, userId)
+	if err != nil {
+		return err
+	}
+
+	err = j.Cache.DeleteHash(ctx, auth.RedisThreadPath+userId)
+	if err != nil {
+		return err
+	}
+
+	var filter models.FilterParams
+	filter.Filter = fmt.Sprintf(`user_id = '%v'`, userId)
+
+	tables := []interface{}{
+		&models.Feedback{},
+		&models.Message{},
+		&models.Memory{},
+		&models.ConversationSummary{},
+		&models.Conversation{},
+		&models.MoodEntry{},
+		&models.JournalEntry{},
+		&models.Reminder{},
+		&models.Device{},
+	}
+	for _, table := range tables {
+		err = j.Db.Delete(ctx, filter, table)
+		if models.AllowErrNotFound(err) != nil {
+			return err
+		}
+	}
+
+	filter.Filter = fmt.Sprintf(`id = '%v'`, userId)
+
+	return models.AllowErrNotFound(j.Db.Delete(ctx, filter, &models.User{}))
+}
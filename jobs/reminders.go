@@ -0,0 +1,188 @@
+package jobs
+
+import (
+	"chatgpt/ai"
+	"chatgpt/models"
+	"chatgpt/search"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const (
+	reminderInterval = time.Minute
+	reminderBatch    = 100
+	reminderTitle    = "TheraChat"
+
+	// checkInInstructions start the conversation from the bot side instead of answering the user.
+	checkInInstructions = "The user scheduled this check-in and is not writing right now. " +
+		"Start the conversation yourself with a short friendly message in the language of the conversation. Check-in: "
+)
+
+// Reminders fires due check-ins. A reminder is claimed by moving its next_run_at
+// before delivery, so with several replicas only one of them sends it.
+type Reminders struct {
+	Db       models.DbClient
+	AI       *ai.AI
+	Notifier models.Notifier
+}
+
+func NewReminders(db models.DbClient, ai *ai.AI, notifier models.Notifier) *Reminders {
+	return &Reminders{
+		Db:       db,
+		AI:       ai,
+		Notifier: notifier,
+	}
+}
+
+// Run fires due reminders until ctx is cancelled.
+func (j *Reminders) Run(ctx context.Context) {
+	ticker := time.NewTicker(reminderInterval)
+	defer ticker.Stop()
+
+	for {
+		j.fireDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (j *Reminders) fireDue(ctx context.Context) {
+	var filter models.FilterParams
+	filter.Filter = fmt.Sprintf(`disabled = false and next_run_at <= '%v'`, time.Now().UTC().Format(time.RFC3339Nano))
+	filter.Orderings = "next_run_at"
+	filter.Limit = reminderBatch
+
+	var reminders []models.Reminder
+	err := j.Db.Get(ctx, filter, &reminders)
+	if models.AllowErrNotFound(err) != nil {
+		slog.ErrorContext(ctx, "due reminders", "error", err)
+		return
+	}
+
+	for _, reminder := range reminders {
+		if ctx.Err() != nil {
+			return
+		}
+
+		user, claimed, err := j.claim(ctx, reminder)
+		if err != nil {
+			slog.ErrorContext(ctx, "claim reminder", "reminder", reminder.Id, "error", err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		err = j.Fire(ctx, user, reminder)
+		if err != nil {
+			slog.ErrorContext(ctx, "fire reminder", "reminder", reminder.Id, "error", err)
+		}
+	}
+}
+
+// claim moves the reminder to its next run, false if another replica did it first.
+func (j *Reminders) claim(ctx context.Context, reminder models.Reminder) (models.User, bool, error) {
+	var filter models.FilterParams
+	filter.Filter = fmt.Sprintf(`id = '%v'`, reminder.UserId.String())
+
+	var user models.User
+	err := j.Db.Get(ctx, filter, &user)
+	if err != nil {
+		return user, false, err
+	}
+
+	previous := reminder.NextRunAt
+	now := time.Now().UTC()
+
+	err = reminder.Schedule(now, models.UserLocation(user))
+	if err != nil {
+		return user, false, err
+	}
+
+	filter.Filter = fmt.Sprintf(`id = '%v' and next_run_at = '%v'`, reminder.Id.String(), previous.Format(time.RFC3339Nano))
+	err = j.Db.Update(ctx, filter, &models.Reminder{NextRunAt: reminder.NextRunAt, LastRunAt: &now})
+	if models.IsErrNotFound(err) {
+		return user, false, nil
+	} else if err != nil {
+		return user, false, err
+	}
+
+	return user, true, nil
+}
+
+// Fire notifies the user, with the bot message as text if the reminder starts the chat.
+func (j *Reminders) Fire(ctx context.Context, user models.User, reminder models.Reminder) error {
+	notification := models.Notification{
+		UserId: user.Id,
+		Title:  reminderTitle,
+		Text:   reminder.Text,
+		Data:   map[string]string{"reminder": reminder.Id.String()},
+	}
+
+	if reminder.StartChat {
+		conversation := reminder.Conversation
+		if conversation == "" {
+			conversation = user.Thread
+		}
+
+		if conversation != "" {
+			message, err := j.startChat(ctx, user, conversation, reminder.Text)
+			if err != nil {
+				return err
+			}
+			notification.Text = message.Text
+			notification.Conversation = conversation
+		}
+	}
+
+	return j.Notifier.Notify(ctx, notification)
+}
+
+func (j *Reminders) startChat(ctx context.Context, user models.User, conversation string, text string) (models.Message, error) {
+	conv, err := models.GetConversation(ctx, j.Db, conversation, user.Id)
+	if err != nil {
+		return models.Message{}, err
+	}
+
+	message, err := j.AI.Run(ctx, conv.ActiveThread(), ai.RunOptions{
+		Persona:      conv.Persona,
+		UserId:       user.Id,
+		Conversation: conversation,
+		Instructions: checkInInstructions + text,
+		Locale:       user.Locale,
+	})
+	if err != nil {
+		return models.Message{}, err
+	}
+
+	branch, err := models.Branch(ctx, j.Db, conv)
+	if err != nil {
+		return models.Message{}, err
+	}
+	if len(branch) > 0 {
+		message.ParentId = branch[len(branch)-1].Id
+	}
+
+	message.Conversation = conversation
+	message.UserId = user.Id
+	message.Language = search.Language(message.Text)
+	err = j.Db.Create(ctx, &message)
+	if err != nil {
+		slog.ErrorContext(ctx, "store message", "message", message.Id, "error", err)
+		return message, nil
+	}
+
+	conv.Leaf = message.Id
+	err = models.SaveBranch(ctx, j.Db, conv)
+	if err != nil {
+		slog.ErrorContext(ctx, "save branch", "conversation", conversation, "error", err)
+	}
+
+	return message, nil
+}
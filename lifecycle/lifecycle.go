@@ -0,0 +1,237 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	DefaultDrainTimeout = 30 * time.Second
+
+	// cancelGrace is given to cancelled requests, workers and closers to clean up,
+	// e.g. to cancel OpenAI runs, after the drain timeout is over.
+	cancelGrace = 10 * time.Second
+)
+
+type closer struct {
+	name  string
+	close func(ctx context.Context) error
+}
+
+// Manager runs the HTTP server and the background workers until SIGINT or SIGTERM, then shuts down in steps:
+// readiness fails, the listener is closed, in-flight requests and detached work get the drain timeout to
+// finish and are cancelled after it, workers are stopped and the registered closers run in order.
+type Manager struct {
+	DrainTimeout time.Duration
+	// DrainDelay keeps serving after readiness fails, so load balancers notice before the listener closes.
+	DrainDelay time.Duration
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+
+	// requests is the base of the request contexts, cancelled once the drain timeout is over.
+	requests       context.Context
+	cancelRequests context.CancelFunc
+	detachedMu     sync.Mutex
+	detached       sync.WaitGroup
+	detachClosed   bool
+
+	onDrain []func()
+	closers []closer
+}
+
+func New(drainTimeout time.Duration, drainDelay time.Duration) *Manager {
+	if drainTimeout <= 0 {
+		drainTimeout = DefaultDrainTimeout
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	requests, cancelRequests := context.WithCancel(context.Background())
+	return &Manager{
+		DrainTimeout:   drainTimeout,
+		DrainDelay:     drainDelay,
+		ctx:            ctx,
+		cancel:         cancel,
+		requests:       requests,
+		cancelRequests: cancelRequests,
+	}
+}
+
+// Context is cancelled when the workers have to stop.
+func (m *Manager) Context() context.Context {
+	return m.ctx
+}
+
+// Go runs a background worker, it must return once its context is cancelled.
+func (m *Manager) Go(name string, worker func(ctx context.Context)) {
+	m.workers.Add(1)
+	go func() {
+		defer m.workers.Done()
+		worker(m.ctx)
+		slog.Info("worker stopped", "worker", name)
+	}()
+}
+
+// Detach returns a context of the request that is not cancelled when the client disconnects, for work whose
+// result is kept anyway. It is cancelled with the requests after the drain timeout, and the closers run only
+// after every detached work called done. Work detached once the shutdown waits for it starts cancelled.
+func (m *Manager) Detach(ctx context.Context) (context.Context, func()) {
+	detached, cancel := context.WithCancel(context.WithoutCancel(ctx))
+
+	m.detachedMu.Lock()
+	defer m.detachedMu.Unlock()
+	if m.detachClosed {
+		cancel()
+		return detached, cancel
+	}
+	m.detached.Add(1)
+
+	stop := context.AfterFunc(m.requests, cancel)
+	return detached, func() {
+		stop()
+		cancel()
+		m.detached.Done()
+	}
+}
+
+// OnDrain registers a func called first on shutdown, e.g. to fail the readiness.
+func (m *Manager) OnDrain(fn func()) {
+	m.onDrain = append(m.onDrain, fn)
+}
+
+// OnClose registers a func called after the requests and workers are done, closers run in order of registration.
+func (m *Manager) OnClose(name string, close func(ctx context.Context) error) {
+	m.closers = append(m.closers, closer{name, close})
+}
+
+// Serve serves the servers until a shutdown signal or an error of any of them and then shuts everything down,
+// see Manager. Servers with a TLSConfig serve HTTPS with its certificates.
+func (m *Manager) Serve(servers ...*http.Server) error {
+	defer m.cancelRequests()
+
+	signals, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, len(servers))
+	for _, server := range servers {
+		server.BaseContext = func(net.Listener) context.Context { return m.requests }
+		go func(server *http.Server) {
+			slog.Info("listening", "addr", server.Addr, "tls", server.TLSConfig != nil)
+			if server.TLSConfig != nil {
+				serveErr <- server.ListenAndServeTLS("", "")
+			} else {
+				serveErr <- server.ListenAndServe()
+			}
+		}(server)
+	}
+
+	var err error
+	select {
+	case <-signals.Done():
+		slog.Info("shutdown started", "drainTimeout", m.DrainTimeout)
+	case err = <-serveErr:
+		slog.Error("serve", "error", err)
+	}
+	stop()
+
+	for _, fn := range m.onDrain {
+		fn()
+	}
+	if err == nil && m.DrainDelay > 0 {
+		time.Sleep(m.DrainDelay)
+	}
+
+	drain, cancelDrain := context.WithTimeout(context.Background(), m.DrainTimeout)
+	defer cancelDrain()
+
+	shutdownErr := shutdown(drain, servers)
+	if errors.Is(shutdownErr, context.DeadlineExceeded) {
+		slog.Warn("drain timeout, cancelling requests")
+		m.cancelRequests()
+
+		grace, cancelGraceCtx := context.WithTimeout(context.Background(), cancelGrace)
+		defer cancelGraceCtx()
+		if shutdown(grace, servers) != nil {
+			for _, server := range servers {
+				server.Close()
+			}
+		}
+	}
+
+	m.waitDetached()
+	m.cancel()
+	m.waitWorkers()
+
+	for _, c := range m.closers {
+		ctx, cancel := context.WithTimeout(context.Background(), cancelGrace)
+		closeErr := c.close(ctx)
+		cancel()
+		if closeErr != nil {
+			slog.Error("close", "name", c.name, "error", closeErr)
+		}
+	}
+
+	slog.Info("shutdown finished")
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// shutdown shuts the servers down at once, so they share the drain timeout.
+func shutdown(ctx context.Context, servers []*http.Server) error {
+	errs := make([]error, len(servers))
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		wg.Add(1)
+		go func(i int, server *http.Server) {
+			defer wg.Done()
+			errs[i] = server.Shutdown(ctx)
+		}(i, server)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// waitDetached waits for the detached work. The servers are shut down, so the requests drained or their
+// drain timeout is over, and the work left is cancelled with them.
+func (m *Manager) waitDetached() {
+	m.detachedMu.Lock()
+	m.detachClosed = true
+	m.detachedMu.Unlock()
+	m.cancelRequests()
+
+	done := make(chan struct{})
+	go func() {
+		m.detached.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(cancelGrace):
+		slog.Warn("detached work did not stop in time")
+	}
+}
+
+func (m *Manager) waitWorkers() {
+	done := make(chan struct{})
+	go func() {
+		m.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(cancelGrace):
+		slog.Warn("workers did not stop in time")
+	}
+}
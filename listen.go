@@ -0,0 +1,78 @@
+package main
+
+import (
+	"chatgpt/certs"
+	"chatgpt/config"
+	"chatgpt/lifecycle"
+	"crypto/tls"
+	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// httpServers builds the API server, over TLS if configured, and the HTTP to HTTPS redirect server.
+func httpServers(configuration *config.Config, handler http.Handler, app *lifecycle.Manager) ([]*http.Server, error) {
+	api := &http.Server{
+		Addr:              ":" + strconv.Itoa(configuration.Port),
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if configuration.H2c {
+		api.Handler = h2c.NewHandler(handler, &http2.Server{})
+	}
+
+	// ACME challenges reach the redirect listener, other requests go to HTTPS.
+	var redirect http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirectToHttps(w, r, configuration.Port)
+	})
+
+	switch {
+	case configuration.TlsCertFile != "":
+		reloader, err := certs.NewReloader(configuration.TlsCertFile, configuration.TlsKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		app.Go("certificate reload", reloader.Run)
+		api.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: reloader.GetCertificate,
+		}
+	case len(configuration.TlsAutocertDomains) > 0:
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			Cache:      autocert.DirCache(configuration.TlsAutocertDir),
+			HostPolicy: autocert.HostWhitelist(configuration.TlsAutocertDomains...),
+			Email:      configuration.TlsAutocertEmail,
+		}
+		api.TLSConfig = manager.TLSConfig()
+		api.TLSConfig.MinVersion = tls.VersionTLS12
+		redirect = manager.HTTPHandler(redirect)
+	}
+
+	servers := []*http.Server{api}
+	if configuration.HttpRedirectPort != 0 {
+		servers = append(servers, &http.Server{
+			Addr:              ":" + strconv.Itoa(configuration.HttpRedirectPort),
+			Handler:           redirect,
+			ReadHeaderTimeout: 10 * time.Second,
+		})
+	}
+	return servers, nil
+}
+
+// redirectToHttps sends the request to the same host and path on the HTTPS port, keeping the method.
+func redirectToHttps(w http.ResponseWriter, r *http.Request, port int) {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	if port != 443 {
+		host = net.JoinHostPort(host, strconv.Itoa(port))
+	}
+
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+}
@@ -0,0 +1,99 @@
+package logging
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+
+	Redacted = "[redacted]"
+)
+
+// sensitive are attribute keys whose values never reach the log.
+var sensitive = map[string]bool{
+	"text":          true,
+	"token":         true,
+	"accesstoken":   true,
+	"refreshtoken":  true,
+	"password":      true,
+	"authorization": true,
+	"email":         true,
+	"phone":         true,
+}
+
+var emailPattern = regexp.MustCompile(`[\w.+-]+@[\w-]+\.[\w.-]+`)
+
+// New builds the logger of the level (debug, info, warn, error) and format (json, text).
+func New(w io.Writer, level string, format string) *slog.Logger {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		l = slog.LevelInfo
+	}
+
+	opts := &slog.HandlerOptions{Level: l, ReplaceAttr: redact}
+
+	var handler slog.Handler
+	if format == FormatText {
+		handler = slog.NewTextHandler(w, opts)
+	} else {
+		handler = slog.NewJSONHandler(w, opts)
+	}
+
+	return slog.New(contextHandler{handler})
+}
+
+// redact hides sensitive attributes and emails inside any string value.
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if sensitive[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, Redacted)
+	}
+	if a.Value.Kind() == slog.KindString {
+		return slog.String(a.Key, emailPattern.ReplaceAllLiteralString(a.Value.String(), Redacted))
+	}
+	if err, ok := a.Value.Any().(error); ok {
+		return slog.String(a.Key, emailPattern.ReplaceAllLiteralString(err.Error(), Redacted))
+	}
+	return a
+}
+
+type requestIdKey struct{}
+
+// WithRequestId stores the request ID, loggers add it to every record of the context.
+func WithRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, id)
+}
+
+func RequestId(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
+
+// contextHandler adds the request ID and the trace ID of the context to records.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestId(ctx); id != "" {
+		r.AddAttrs(slog.String("requestId", id))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.HasTraceID() {
+		r.AddAttrs(slog.String("traceId", span.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
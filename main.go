@@ -5,57 +5,154 @@ import (
 	h "chatgpt/api/handler"
 	f "chatgpt/auth/firebase"
 	"chatgpt/config"
+	"chatgpt/docs"
+	"chatgpt/flags"
+	"chatgpt/jobs"
+	"chatgpt/lifecycle"
+	"chatgpt/logging"
+	"chatgpt/metrics"
 	"chatgpt/models"
+	"chatgpt/notify"
+	"chatgpt/push"
 	s "chatgpt/server"
+	"chatgpt/settings"
 	"chatgpt/store"
+	"chatgpt/tracing"
 	"context"
-	"gopkg.in/tylerb/graceful.v1"
-	"strconv"
-
-	_ "chatgpt/docs"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
 )
 
 //	@title			TheraChat API
 //	@version		1.0
 //	@description	This is a server for communication with ChatGPT.
 
-//	@host	http://64.226.106.122:8080
+//	@schemes	https http
 
 // @securityDefinitions.apikey	BearerAuth
 // @in							header
 // @name						Authorization
 func main() {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(configCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "contract" {
+		os.Exit(contractCommand())
+	}
 
-	configuration := config.NewConfiguration()
+	configuration, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err == nil {
+		err = configuration.Validate()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(2)
+	}
+
+	logger := logging.New(os.Stdout, configuration.LogLevel, configuration.LogFormat)
+	slog.SetDefault(logger)
+
+	app := lifecycle.New(
+		time.Duration(configuration.ShutdownDrainSeconds)*time.Second,
+		time.Duration(configuration.ShutdownDelaySeconds)*time.Second)
+	ctx := app.Context()
+
+	shutdownTracing, err := tracing.New(ctx, configuration)
+	if err != nil {
+		panic(err)
+	}
 
 	var db models.DbClient
-	err := store.NewDB(configuration, &db)
+	err = store.NewDB(configuration, &db)
+	if err != nil {
+		panic(err)
+	}
+
+	err = db.Migrate(ctx, models.Tables()...)
 	if err != nil {
 		panic(err)
 	}
-	defer db.CloseClient()
 
 	var cache models.CacheClient
 	err = store.NewCacheClient(ctx, configuration, &cache)
 	if err != nil {
 		panic(err)
 	}
-	defer cache.CloseClient()
 
 	ai := a.NewAI(configuration)
+	err = ai.LoadPersonas(ctx, db)
+	if err != nil {
+		panic(err)
+	}
+	ai.UseMemory(db, configuration.MemorySummaryEvery, configuration.MemoryModel)
+	ai.RegisterTool(a.NewLogMoodTool(db))
 
-	firebase, err := f.NewFirebaseAuthenticator(ctx)
+	firebase, err := f.NewFirebaseAuthenticator(ctx, configuration.FirebaseCredentialsPath)
 	if err != nil {
 		panic(err)
 	}
 
-	server := s.NewApiServer(configuration, db, cache, ai, firebase)
+	pusher, err := push.New(ctx, configuration, db)
+	if err != nil {
+		panic(err)
+	}
+
+	runtimeSettings := settings.New(cache, configuration, func() (*config.Config, error) {
+		return config.Load(flag.NewFlagSet(os.Args[0], flag.ContinueOnError), os.Args[1:])
+	})
+	runtimeSettings.OnChange(func(ctx context.Context, settings models.Settings) error {
+		ai.Configure(settings.Personas, settings.DefaultPersona)
+		return ai.LoadPersonas(ctx, db)
+	})
+	err = runtimeSettings.Reload(ctx)
+	if err != nil {
+		panic(err)
+	}
+
+	server := s.NewApiServer(configuration, db, cache, ai, firebase, pusher, logger, runtimeSettings, flags.New(db), app)
 	server.Init(ctx)
 
+	// The spec has no host, swagger calls the server it is served from.
+	if configuration.TLS() {
+		docs.SwaggerInfo.Schemes = []string{"https"}
+	}
+
+	notifier, err := notify.New(configuration, pusher)
+	if err != nil {
+		panic(err)
+	}
+
+	if configuration.MetricsAddr != "" {
+		app.Go("metrics", func(ctx context.Context) {
+			metrics.Serve(ctx, configuration.MetricsAddr, configuration.MetricsToken)
+		})
+	}
+
+	app.Go("settings", runtimeSettings.Run)
+	app.Go("account deletion", jobs.NewAccountDeletion(db, cache, ai).Run)
+	app.Go("reminders", jobs.NewReminders(db, ai, notifier).Run)
+
 	handler := h.NewHandler(server)
 	handler.InitRoutes()
 
-	graceful.Run(":"+strconv.Itoa(server.Configuration.Port), 0, server.Router)
+	// Memory updates still write to the database, the spans of all of them are flushed last.
+	app.OnDrain(server.SetDraining)
+	app.OnClose("memory", ai.Close)
+	app.OnClose("db", func(context.Context) error { return db.CloseClient() })
+	app.OnClose("cache", func(context.Context) error { return cache.CloseClient() })
+	app.OnClose("tracing", shutdownTracing)
+
+	servers, err := httpServers(configuration, server.Router, app)
+	if err != nil {
+		panic(err)
+	}
+
+	err = app.Serve(servers...)
+	if err != nil {
+		slog.Error("server", "error", err)
+		os.Exit(1)
+	}
 }
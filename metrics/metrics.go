@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sashabaranov/go-openai"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const namespace = "thera"
+
+const (
+	StoreDb    = "db"
+	StoreRedis = "redis"
+)
+
+// runBuckets cover assistant runs, which poll every few seconds and may take minutes with tool calls.
+var runBuckets = []float64{1, 2, 4, 6, 8, 10, 15, 20, 30, 45, 60, 90, 120, 180, 300}
+
+var (
+	HTTPRequests = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of HTTP requests by route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	OpenAICalls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "openai_calls_total",
+		Help:      "OpenAI API calls by endpoint and status, status is error if no response was received.",
+	}, []string{"method", "endpoint", "status"})
+
+	OpenAIRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "openai_runs_total",
+		Help:      "Assistant runs by final status.",
+	}, []string{"status"})
+
+	OpenAIRunWait = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "openai_run_wait_seconds",
+		Help:      "Time from the creation of an assistant run to its final status.",
+		Buckets:   runBuckets,
+	}, []string{"status"})
+
+	OpenAITokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "openai_tokens_total",
+		Help:      "Tokens used by chat completions and assistant runs by model and kind, prompt or completion.",
+	}, []string{"model", "kind"})
+
+	StoreErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "store_errors_total",
+		Help:      "Failed database and Redis operations, not found results are not counted.",
+	}, []string{"store", "operation"})
+)
+
+// ObserveRun records the final status of an assistant run and how long it was waited for.
+func ObserveRun(status string, started time.Time) {
+	OpenAIRuns.WithLabelValues(status).Inc()
+	OpenAIRunWait.WithLabelValues(status).Observe(time.Since(started).Seconds())
+}
+
+// ObserveUsage counts the tokens of a chat completion or a completed assistant run.
+func ObserveUsage(model string, usage openai.Usage) {
+	OpenAITokens.WithLabelValues(model, "prompt").Add(float64(usage.PromptTokens))
+	OpenAITokens.WithLabelValues(model, "completion").Add(float64(usage.CompletionTokens))
+}
+
+// Handler serves the metrics, requests must carry the token as a bearer token if it is set.
+func Handler(token string) http.Handler {
+	handler := promhttp.Handler()
+	if token == "" {
+		return handler
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// Serve exposes the metrics on a separate address, so they are not reachable through the public API port.
+// It returns once ctx is cancelled.
+func Serve(ctx context.Context, addr string, token string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler(token))
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	err := server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("metrics server", "addr", addr, "error", err)
+	}
+}
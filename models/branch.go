@@ -0,0 +1,89 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"slices"
+	"strings"
+)
+
+// ActiveThread is the OpenAI thread holding exactly the messages of the active branch.
+func (c Conversation) ActiveThread() string {
+	if c.Thread != "" {
+		return c.Thread
+	}
+	return c.Id
+}
+
+// GetConversation returns the conversation of the user. Main threads started before conversations
+// were stored have no row, they are returned with the ID and the user only.
+func GetConversation(ctx context.Context, db DbClient, id string, userId uuid.UUID) (Conversation, error) {
+	var filter FilterParams
+	filter.Filter = fmt.Sprintf(`id = '%v' and user_id = '%v'`, strings.ReplaceAll(id, "'", "''"), userId.String())
+
+	var conversation Conversation
+	err := db.Get(ctx, filter, &conversation)
+	if IsErrNotFound(err) {
+		return Conversation{Id: id, UserId: userId}, nil
+	}
+	return conversation, err
+}
+
+// SaveBranch stores the active thread and leaf of the conversation.
+func SaveBranch(ctx context.Context, db DbClient, conversation Conversation) error {
+	var filter FilterParams
+	filter.Filter = fmt.Sprintf(`id = '%v'`, strings.ReplaceAll(conversation.Id, "'", "''"))
+
+	return db.Upsert(ctx, filter, &conversation)
+}
+
+// ConversationMessages returns all stored messages of the conversation tree, oldest first.
+func ConversationMessages(ctx context.Context, db DbClient, conversation Conversation) ([]Message, error) {
+	var filter FilterParams
+	filter.Filter = fmt.Sprintf(`conversation = '%v' and user_id = '%v'`,
+		strings.ReplaceAll(conversation.Id, "'", "''"), conversation.UserId.String())
+	filter.Orderings = "created_at"
+
+	messages := make([]Message, 0)
+	err := db.Get(ctx, filter, &messages)
+	return messages, AllowErrNotFound(err)
+}
+
+// Branch returns the messages of the active branch from the first one to the leaf.
+func Branch(ctx context.Context, db DbClient, conversation Conversation) ([]Message, error) {
+	messages, err := ConversationMessages(ctx, db, conversation)
+	if err != nil || len(messages) == 0 {
+		return messages, err
+	}
+
+	leaf := conversation.Leaf
+	if leaf == "" {
+		leaf = messages[len(messages)-1].Id
+	}
+	return Path(messages, leaf), nil
+}
+
+// Path follows the parents of the message with the id and returns the messages from the first one to it,
+// none if it is not among the messages.
+func Path(messages []Message, id string) []Message {
+	byId := make(map[string]Message, len(messages))
+	for _, m := range messages {
+		byId[m.Id] = m
+	}
+
+	path := make([]Message, 0)
+	for id != "" {
+		m, ok := byId[id]
+		if !ok {
+			break
+		}
+		// a message is visited once, so broken parent links can not loop
+		delete(byId, id)
+		path = append(path, m)
+		id = m.ParentId
+	}
+
+	slices.Reverse(path)
+	return path
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	PlatformAndroid = "android"
+	PlatformIOS     = "ios"
+	PlatformWeb     = "web"
+
+	ProviderFCM  = "fcm"
+	ProviderAPNs = "apns"
+
+	DeviceTokenMaxLength = 4096
+)
+
+// Device is a push token of the user app, a token belongs to one user at a time.
+type Device struct {
+	Id        uuid.UUID `json:"id" gorm:"type:uuid;default:uuid_generate_v4()"`
+	UserId    uuid.UUID `json:"-" gorm:"type:uuid;index"`
+	Token     string    `json:"token" gorm:"uniqueIndex"`
+	Provider  string    `json:"provider"`
+	Platform  string    `json:"platform"`
+	Locale    string    `json:"locale"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type DeviceFields struct {
+	Token    string `json:"token"`
+	Provider string `json:"provider"`
+	Platform string `json:"platform"`
+	Locale   string `json:"locale"`
+}
+
+func (d *DeviceFields) Validate() error {
+	d.Token = strings.TrimSpace(d.Token)
+	if d.Token == "" || len(d.Token) > DeviceTokenMaxLength {
+		return AdvancedErrorResponse{
+			Key:     "token_field",
+			Code:    http.StatusBadRequest,
+			Message: "Поле 'token' должно быть заполнено.",
+		}
+	}
+
+	if d.Provider == "" {
+		d.Provider = ProviderFCM
+	}
+	if d.Provider != ProviderFCM && d.Provider != ProviderAPNs {
+		return AdvancedErrorResponse{
+			Key:     "provider_field",
+			Code:    http.StatusBadRequest,
+			Message: "Поле 'provider' должно быть 'fcm' или 'apns'.",
+		}
+	}
+
+	if d.Platform != PlatformAndroid && d.Platform != PlatformIOS && d.Platform != PlatformWeb {
+		return AdvancedErrorResponse{
+			Key:     "platform_field",
+			Code:    http.StatusBadRequest,
+			Message: "Поле 'platform' должно быть 'android', 'ios' или 'web'.",
+		}
+	}
+
+	return nil
+}
+
+type DeviceTokenFields struct {
+	Token string `json:"token"`
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"net/http"
+	"slices"
+	"time"
+)
+
+const (
+	RatingUp   = "up"
+	RatingDown = "down"
+
+	FeedbackCommentMaxLength = 2000
+)
+
+var FeedbackReasons = []string{
+	"helpful",
+	"empathetic",
+	"clear",
+	"inaccurate",
+	"unhelpful",
+	"unsafe",
+	"off_topic",
+	"too_long",
+	"repetitive",
+}
+
+// Feedback is a rating of the assistant reply, one per user and message.
+type Feedback struct {
+	Id        uuid.UUID `json:"id" gorm:"type:uuid;default:uuid_generate_v4()"`
+	MessageId string    `json:"messageId" gorm:"uniqueIndex:idx_feedback_message_user"`
+	UserId    uuid.UUID `json:"-" gorm:"type:uuid;uniqueIndex:idx_feedback_message_user"`
+	Assistant string    `json:"assistant" gorm:"index"`
+	Model     string    `json:"model" gorm:"index"`
+	Rating    int       `json:"rating"`
+	Reasons   []string  `json:"reasons" gorm:"serializer:json"`
+	Comment   string    `json:"comment"`
+	CreatedAt time.Time `json:"createdAt" gorm:"default:now();index"`
+}
+
+type FeedbackFields struct {
+	Rating  string   `json:"rating" enums:"up,down"`
+	Reasons []string `json:"reasons"`
+	Comment string   `json:"comment"`
+}
+
+func (f *FeedbackFields) Validate() error {
+	if f.Rating != RatingUp && f.Rating != RatingDown {
+		return AdvancedErrorResponse{
+			Key:     "rating_field",
+			Code:    http.StatusBadRequest,
+			Message: "Поле 'rating' должно быть 'up' или 'down'.",
+		}
+	}
+
+	for _, reason := range f.Reasons {
+		if !slices.Contains(FeedbackReasons, reason) {
+			return AdvancedErrorResponse{
+				Key:     "reasons_field",
+				Code:    http.StatusBadRequest,
+				Message: "Поле 'reasons' содержит неизвестную причину.",
+			}
+		}
+	}
+
+	if len([]rune(f.Comment)) > FeedbackCommentMaxLength {
+		return AdvancedErrorResponse{
+			Key:     "comment_field",
+			Code:    http.StatusBadRequest,
+			Message: "Поле 'comment' слишком длинное.",
+		}
+	}
+
+	return nil
+}
+
+// Score returns the rating as +1 or -1.
+func (f *FeedbackFields) Score() int {
+	if f.Rating == RatingUp {
+		return 1
+	}
+	return -1
+}
+
+type FeedbackAggregate struct {
+	Assistant string    `json:"assistant"`
+	Model     string    `json:"model"`
+	Day       time.Time `json:"day"`
+	Total     int       `json:"total"`
+	Up        int       `json:"up"`
+	Down      int       `json:"down"`
+	AvgRating float64   `json:"avgRating"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var flagKeyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,63}$`)
+
+// FeatureFlag turns a feature on for the listed users and roles and for a stable share of everybody else.
+type FeatureFlag struct {
+	Key         string `json:"key" gorm:"primaryKey"`
+	Description string `json:"description"`
+	// Enabled false turns the flag off for everybody, including the listed users and roles.
+	Enabled bool `json:"enabled"`
+	// Percentage of users and anonymous sessions the flag is on for, 100 is everybody.
+	Percentage int       `json:"percentage"`
+	Roles      []string  `json:"roles" gorm:"serializer:json"`
+	Users      []string  `json:"users" gorm:"serializer:json"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+type FeatureFlagFields struct {
+	Key         string   `json:"key"`
+	Description string   `json:"description"`
+	Enabled     bool     `json:"enabled"`
+	Percentage  int      `json:"percentage"`
+	Roles       []string `json:"roles"`
+	Users       []string `json:"users"`
+}
+
+func (f *FeatureFlagFields) Validate() error {
+	f.Key = strings.TrimSpace(f.Key)
+	if !flagKeyPattern.MatchString(f.Key) {
+		return AdvancedErrorResponse{
+			Key:     "key_field",
+			Code:    http.StatusBadRequest,
+			Message: "Поле 'key' должно содержать от 1 до 64 символов: строчные латинские буквы, цифры, '_', '.' или '-'.",
+		}
+	}
+
+	if f.Percentage < 0 || f.Percentage > 100 {
+		return AdvancedErrorResponse{
+			Key:     "percentage_field",
+			Code:    http.StatusBadRequest,
+			Message: "Поле 'percentage' должно быть от 0 до 100.",
+		}
+	}
+
+	return nil
+}
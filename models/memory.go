@@ -0,0 +1,35 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	MemoryKindName       = "name"
+	MemoryKindGoal       = "goal"
+	MemoryKindTrigger    = "trigger"
+	MemoryKindPreference = "preference"
+	MemoryKindFact       = "fact"
+)
+
+var MemoryKinds = []string{MemoryKindName, MemoryKindGoal, MemoryKindTrigger, MemoryKindPreference, MemoryKindFact}
+
+// Memory is a durable fact about the user extracted from conversations.
+type Memory struct {
+	Id           uuid.UUID `json:"id" gorm:"type:uuid;default:uuid_generate_v4()"`
+	UserId       uuid.UUID `json:"-" gorm:"type:uuid;index"`
+	Kind         string    `json:"kind"`
+	Text         string    `json:"text"`
+	Conversation string    `json:"conversation"`
+	CreatedAt    time.Time `json:"createdAt" gorm:"default:now()"`
+}
+
+// ConversationSummary is the running summary of the first MessageCount messages of the conversation.
+type ConversationSummary struct {
+	Conversation string    `json:"conversation" gorm:"primaryKey"`
+	UserId       uuid.UUID `json:"-" gorm:"type:uuid;index"`
+	Summary      string    `json:"summary"`
+	MessageCount int       `json:"messageCount"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
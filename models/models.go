@@ -1,25 +1,143 @@
 package models
 
 import (
+	"context"
+	"fmt"
 	"github.com/google/uuid"
 	"time"
 )
 
+// Tables lists models managed by DbClient.Migrate.
+func Tables() []interface{} {
+	return []interface{}{
+		&User{},
+		&Message{},
+		&Feedback{},
+		&Conversation{},
+		&Persona{},
+		&Memory{},
+		&ConversationSummary{},
+		&MoodEntry{},
+		&JournalEntry{},
+		&Reminder{},
+		&Device{},
+		&FeatureFlag{},
+	}
+}
+
 type User struct {
-	Id        uuid.UUID `json:"id" gorm:"default:uuid_generate_v4()"`
-	Phone     string    `json:"phone"`
-	Password  string    `json:"-"`
-	Roles     string    `json:"roles" gorm:"default:user"`
-	Email     string    `json:"email"`
-	Name      string    `json:"name"`
-	Surname   string    `json:"surname"`
-	Thread    string    `json:"thread"`
-	IsGoogle  bool      `json:"isGoogle"`
-	IsApple   bool      `json:"isApple"`
-	CreatedAt time.Time `json:"createdAt" gorm:"default:now()"`
+	Id        uuid.UUID  `json:"id" gorm:"type:uuid;default:uuid_generate_v4()"`
+	Phone     string     `json:"phone"`
+	Password  string     `json:"-"`
+	Roles     string     `json:"roles" gorm:"default:user"`
+	Email     string     `json:"email"`
+	Name      string     `json:"name"`
+	Surname   string     `json:"surname"`
+	Thread    string     `json:"thread"`
+	IsGoogle  bool       `json:"isGoogle"`
+	IsApple   bool       `json:"isApple"`
+	Timezone  string     `json:"timezone" gorm:"default:'UTC'"`
+	Locale    string     `json:"locale"`
+	CreatedAt time.Time  `json:"createdAt" gorm:"default:now()"`
+	DeleteAt  *time.Time `json:"deleteAt,omitempty"`
 }
 
+// Message is stored under the OpenAI message ID, so the ID is stable between the API and the local history.
+// ParentId links a message to the previous one of its branch, regenerated replies and edited messages are siblings.
 type Message struct {
-	Role string `json:"role,omitempty"`
+	Id           string    `json:"id,omitempty" gorm:"primaryKey"`
+	Conversation string    `json:"conversation,omitempty" gorm:"index"`
+	ParentId     string    `json:"parentId,omitempty" gorm:"index"`
+	UserId       uuid.UUID `json:"-" gorm:"type:uuid;index"`
+	Role         string    `json:"role,omitempty"`
+	Text         string    `json:"text"`
+	Language     string    `json:"-" gorm:"type:regconfig;default:'english'"`
+	Search       string    `json:"-" gorm:"->;type:tsvector GENERATED ALWAYS AS (to_tsvector(language, text)) STORED;index:,type:gin"`
+	Assistant    string    `json:"-"`
+	Model        string    `json:"-"`
+	CreatedAt    int64     `json:"createdAt,omitempty"`
+}
+
+// SearchHit is a matching message, the Snippet is HTML-escaped text with the matches in <mark>.
+type SearchHit struct {
+	MessageId    string `json:"messageId"`
+	Conversation string `json:"conversation"`
+	Role         string `json:"role"`
+	Snippet      string `json:"snippet"`
+	CreatedAt    int64  `json:"createdAt"`
+}
+
+type StartChatFields struct {
+	Persona string `json:"persona"`
+}
+
+// ProfileFields are the user columns the user may change, empty ones are kept.
+type ProfileFields struct {
+	Name     string `json:"name"`
+	Surname  string `json:"surname"`
+	Timezone string `json:"timezone"`
+	Locale   string `json:"locale"`
+}
+
+type MessageFields struct {
 	Text string `json:"text"`
 }
+
+type BranchFields struct {
+	// Leaf is the ID of the last message of the branch.
+	Leaf string `json:"leaf"`
+}
+
+// Conversation binds the OpenAI thread to its owner and persona.
+type Conversation struct {
+	Id      string    `json:"id" gorm:"primaryKey"`
+	UserId  uuid.UUID `json:"-" gorm:"type:uuid;index"`
+	Persona string    `json:"persona"`
+	// Thread is the OpenAI thread forked for the active branch, the thread of the ID if empty.
+	Thread string `json:"-"`
+	// Leaf is the last message of the active branch, the newest message if empty.
+	Leaf      string    `json:"leaf,omitempty"`
+	CreatedAt time.Time `json:"createdAt" gorm:"default:now()"`
+}
+
+// UserConversations returns ids of all user threads, the current one first.
+func UserConversations(ctx context.Context, db DbClient, user User) ([]string, error) {
+	var filter FilterParams
+	filter.Filter = fmt.Sprintf(`user_id = '%v'`, user.Id.String())
+	filter.Orderings = "created_at"
+
+	var conversations []Conversation
+	err := db.Get(ctx, filter, &conversations)
+	if AllowErrNotFound(err) != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(conversations)+1)
+	if user.Thread != "" {
+		ids = append(ids, user.Thread)
+	}
+	for _, c := range conversations {
+		if c.Id != user.Thread {
+			ids = append(ids, c.Id)
+		}
+	}
+
+	return ids, nil
+}
+
+// Persona is an assistant setup the user can talk to.
+// Empty fields fall back to the OpenAI assistant settings.
+type Persona struct {
+	Id           string `json:"id" gorm:"primaryKey"`
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	AssistantId  string `json:"assistantId,omitempty"`
+	Instructions string `json:"instructions,omitempty"`
+	Model        string `json:"model,omitempty"`
+	// Temperature of the runs from 0 to 2, the assistant one if unset.
+	Temperature *float32 `json:"temperature,omitempty"`
+	// Tools are names of function tools registered in package ai, they replace the assistant tools.
+	// Unset keeps the assistant tools and adds all registered ones.
+	Tools    []string `json:"tools,omitempty" gorm:"serializer:json"`
+	Disabled bool     `json:"disabled,omitempty"`
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	MoodScoreMin       = 1
+	MoodScoreMax       = 10
+	MoodEmotionsMax    = 10
+	MoodNoteMaxLength  = 2000
+	JournalTitleMaxLen = 200
+
+	SourceUser      = "user"
+	SourceAssistant = "assistant"
+)
+
+type MoodEntry struct {
+	Id           uuid.UUID `json:"id" gorm:"type:uuid;default:uuid_generate_v4()"`
+	UserId       uuid.UUID `json:"-" gorm:"type:uuid;index"`
+	Score        int       `json:"score"`
+	Emotions     []string  `json:"emotions" gorm:"serializer:json"`
+	Note         string    `json:"note"`
+	Source       string    `json:"source"`
+	Conversation string    `json:"conversation,omitempty"`
+	CreatedAt    time.Time `json:"createdAt" gorm:"index"`
+}
+
+type MoodFields struct {
+	Score    int        `json:"score"`
+	Emotions []string   `json:"emotions"`
+	Note     string     `json:"note"`
+	Time     *time.Time `json:"time"`
+}
+
+func (m *MoodFields) Validate() error {
+	if m.Score < MoodScoreMin || m.Score > MoodScoreMax {
+		return AdvancedErrorResponse{
+			Key:     "score_field",
+			Code:    http.StatusBadRequest,
+			Message: "Поле 'score' должно быть от 1 до 10.",
+		}
+	}
+
+	if len(m.Emotions) > MoodEmotionsMax {
+		return AdvancedErrorResponse{
+			Key:     "emotions_field",
+			Code:    http.StatusBadRequest,
+			Message: "Поле 'emotions' содержит слишком много значений.",
+		}
+	}
+
+	for i, e := range m.Emotions {
+		m.Emotions[i] = strings.ToLower(strings.TrimSpace(e))
+	}
+
+	if len([]rune(m.Note)) > MoodNoteMaxLength {
+		return AdvancedErrorResponse{
+			Key:     "note_field",
+			Code:    http.StatusBadRequest,
+			Message: "Поле 'note' слишком длинное.",
+		}
+	}
+
+	return nil
+}
+
+type JournalEntry struct {
+	Id        uuid.UUID `json:"id" gorm:"type:uuid;default:uuid_generate_v4()"`
+	UserId    uuid.UUID `json:"-" gorm:"type:uuid;index"`
+	Title     string    `json:"title"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"createdAt" gorm:"index"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type JournalFields struct {
+	Title string `json:"title"`
+	Text  string `json:"text"`
+}
+
+func (j *JournalFields) Validate() error {
+	if strings.TrimSpace(j.Text) == "" {
+		return AdvancedErrorResponse{
+			Key:     "text_field",
+			Code:    http.StatusBadRequest,
+			Message: "Поле 'text' должно быть заполнено.",
+		}
+	}
+
+	if len([]rune(j.Title)) > JournalTitleMaxLen {
+		return AdvancedErrorResponse{
+			Key:     "title_field",
+			Code:    http.StatusBadRequest,
+			Message: "Поле 'title' слишком длинное.",
+		}
+	}
+
+	return nil
+}
+
+type MoodAggregate struct {
+	Period   time.Time `json:"period"`
+	Count    int       `json:"count"`
+	AvgScore float64   `json:"avgScore"`
+	MinScore int       `json:"minScore"`
+	MaxScore int       `json:"maxScore"`
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	FrequencyDaily  = "daily"
+	FrequencyWeekly = "weekly"
+
+	ReminderTextMaxLength = 500
+	ReminderTimeLayout    = "15:04"
+)
+
+// Reminder is a scheduled check-in, NextRunAt is the next firing time in UTC.
+type Reminder struct {
+	Id        uuid.UUID `json:"id" gorm:"type:uuid;default:uuid_generate_v4()"`
+	UserId    uuid.UUID `json:"-" gorm:"type:uuid;index"`
+	Text      string    `json:"text"`
+	Frequency string    `json:"frequency"`
+	// Weekday of weekly reminders, 0 is Sunday.
+	Weekday int    `json:"weekday"`
+	Time    string `json:"time"`
+	// Conversation to start the bot message in, the current thread of the user if empty.
+	Conversation string     `json:"conversation,omitempty"`
+	StartChat    bool       `json:"startChat"`
+	Disabled     bool       `json:"disabled"`
+	NextRunAt    time.Time  `json:"nextRunAt" gorm:"index"`
+	LastRunAt    *time.Time `json:"lastRunAt,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt" gorm:"default:now()"`
+}
+
+// Schedule sets NextRunAt to the first firing after the given moment in the user time zone.
+func (r *Reminder) Schedule(after time.Time, location *time.Location) error {
+	clock, err := time.Parse(ReminderTimeLayout, r.Time)
+	if err != nil {
+		return err
+	}
+
+	local := after.In(location)
+	next := time.Date(local.Year(), local.Month(), local.Day(), clock.Hour(), clock.Minute(), 0, 0, location)
+
+	if r.Frequency == FrequencyWeekly {
+		next = next.AddDate(0, 0, (r.Weekday-int(next.Weekday())+7)%7)
+	}
+
+	for !next.After(after) {
+		if r.Frequency == FrequencyWeekly {
+			next = next.AddDate(0, 0, 7)
+		} else {
+			next = next.AddDate(0, 0, 1)
+		}
+	}
+
+	r.NextRunAt = next.UTC()
+	return nil
+}
+
+type ReminderFields struct {
+	Text         string `json:"text"`
+	Frequency    string `json:"frequency"`
+	Weekday      int    `json:"weekday"`
+	Time         string `json:"time"`
+	Conversation string `json:"conversation"`
+	StartChat    bool   `json:"startChat"`
+	Disabled     bool   `json:"disabled"`
+}
+
+func (r *ReminderFields) Validate() error {
+	r.Text = strings.TrimSpace(r.Text)
+	if r.Text == "" {
+		return AdvancedErrorResponse{
+			Key:     "text_field",
+			Code:    http.StatusBadRequest,
+			Message: "Поле 'text' должно быть заполнено.",
+		}
+	}
+
+	if len([]rune(r.Text)) > ReminderTextMaxLength {
+		return AdvancedErrorResponse{
+			Key:     "text_length",
+			Code:    http.StatusBadRequest,
+			Message: "Поле 'text' слишком длинное.",
+		}
+	}
+
+	if r.Frequency != FrequencyDaily && r.Frequency != FrequencyWeekly {
+		return AdvancedErrorResponse{
+			Key:     "frequency_field",
+			Code:    http.StatusBadRequest,
+			Message: "Поле 'frequency' должно быть 'daily' или 'weekly'.",
+		}
+	}
+
+	if r.Weekday < 0 || r.Weekday > 6 {
+		return AdvancedErrorResponse{
+			Key:     "weekday_field",
+			Code:    http.StatusBadRequest,
+			Message: "Поле 'weekday' должно быть от 0 (воскресенье) до 6.",
+		}
+	}
+
+	_, err := time.Parse(ReminderTimeLayout, r.Time)
+	if err != nil {
+		return AdvancedErrorResponse{
+			Key:     "time_field",
+			Code:    http.StatusBadRequest,
+			Message: "Поле 'time' должно быть временем в формате HH:MM.",
+		}
+	}
+
+	return nil
+}
+
+// Notification is a message to the user delivered outside of the chat.
+type Notification struct {
+	UserId       uuid.UUID         `json:"userId"`
+	Title        string            `json:"title"`
+	Text         string            `json:"text"`
+	Conversation string            `json:"conversation,omitempty"`
+	Data         map[string]string `json:"data,omitempty"`
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
+
+// UserLocation returns the time zone of the user, UTC if it is unset or unknown.
+func UserLocation(user User) *time.Location {
+	location, err := time.LoadLocation(user.Timezone)
+	if err != nil || user.Timezone == "" {
+		return time.UTC
+	}
+	return location
+}
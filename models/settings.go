@@ -0,0 +1,69 @@
+package models
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	SettingsSourceConfig = "config"
+	SettingsSourceRedis  = "redis"
+)
+
+// Settings are the part of the configuration that can change without a restart.
+type Settings struct {
+	// Personas are merged with the personas table, the table wins on the same id.
+	Personas       []Persona `json:"personas"`
+	DefaultPersona string    `json:"defaultPersona"`
+	// CorsOrigins allowed to call the API from browsers, none if empty and all of them with "*".
+	CorsOrigins []string `json:"corsOrigins"`
+}
+
+// EffectiveSettings are the settings in use and where they came from.
+type EffectiveSettings struct {
+	Settings
+	Source string `json:"source"`
+	// Version of the stored settings, equal on replicas that applied the same settings, 0 for the config.
+	Version   int64      `json:"version"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+	LoadedAt  time.Time  `json:"loadedAt"`
+}
+
+func (s *Settings) Validate() error {
+	ids := make(map[string]bool, len(s.Personas))
+	for _, p := range s.Personas {
+		if p.Id == "" || ids[p.Id] {
+			return AdvancedErrorResponse{
+				Key:     "personas_field",
+				Code:    http.StatusBadRequest,
+				Message: "Поле 'personas' должно содержать персоны с уникальными непустыми id.",
+			}
+		}
+		ids[p.Id] = true
+
+		if p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > 2) {
+			return AdvancedErrorResponse{
+				Key:     "persona_temperature_field",
+				Code:    http.StatusBadRequest,
+				Message: "Поле 'temperature' персоны должно быть от 0 до 2.",
+			}
+		}
+	}
+
+	for _, origin := range s.CorsOrigins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			return AdvancedErrorResponse{
+				Key:     "cors_origins_field",
+				Code:    http.StatusBadRequest,
+				Message: "Поле 'corsOrigins' должно содержать адреса вида https://example.com или *.",
+			}
+		}
+	}
+
+	return nil
+}
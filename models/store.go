@@ -29,34 +29,47 @@ func AllowErrNotFound(err error) error {
 
 type DbClient interface {
 	PingClient(ctx context.Context) error
-	//Migrate(ctx context.Context, isViewMigrate bool) error
+	Migrate(ctx context.Context, tables ...interface{}) error
 	Create(ctx context.Context, input interface{}) error
 	Get(ctx context.Context, params FilterParams, out interface{}) error
 	GetView(ctx context.Context, viewName string, params FilterParams, out interface{}) error
 	Select(ctx context.Context, table string, params FilterParams, out interface{}) error
+	// Raw runs the query with the args bound to its placeholders, ? or @name with a map, and scans the rows into out.
+	Raw(ctx context.Context, out interface{}, query string, args ...interface{}) error
 	Update(ctx context.Context, params FilterParams, input interface{}) error
 	Upsert(ctx context.Context, params FilterParams, input interface{}) error
+	// Replace updates all columns of the one row matching the filter, unlike Update zero and false values too.
+	// It rolls back unless exactly one row matched.
+	Replace(ctx context.Context, params FilterParams, input interface{}) error
+	// UpsertOnConflict inserts the row or, in one statement, updates the row conflicting on the unique columns.
+	UpsertOnConflict(ctx context.Context, input interface{}, columns ...string) error
 	Delete(ctx context.Context, params FilterParams, input interface{}) error
 	CloseClient() error
 }
 
 type CacheClient interface {
+	PingClient(ctx context.Context) error
 	SetHash(ctx context.Context, key string, objectType interface{}, expTime time.Duration) error
+	// SetHashNX sets the key only if it does not exist and tells if it did.
+	SetHashNX(ctx context.Context, key string, objectType interface{}, expTime time.Duration) (bool, error)
 	GetHash(ctx context.Context, key string, out interface{}) error
 	DeleteHash(ctx context.Context, key string) error
 	GetKeys(ctx context.Context, pattern string, out *[]string) error
+	// AddToSet adds the members to the set and sets its expiration, it is extended on every call.
+	AddToSet(ctx context.Context, key string, expTime time.Duration, members ...string) error
+	GetSet(ctx context.Context, key string, out *[]string) error
 	GetList(ctx context.Context, list string, out interface{}) error
 	PushToList(ctx context.Context, key string, objectType interface{}) error
-	SubScribe(ctx context.Context, topics ...string) error
-	ReceiveMsg(ctx context.Context, out *redis.Message) error
+	// SubScribe returns a subscription to the topics, the caller owns and closes it.
+	SubScribe(ctx context.Context, topics ...string) (*redis.PubSub, error)
 	PublishMsg(ctx context.Context, topic string, msg interface{}) error
-	CloseSub(ctx context.Context) error
 	CloseClient() error
 }
 
 type FilterParams struct {
 	Filter string
 	Select string
+	Group  string
 	FeedParams
 }
 
@@ -0,0 +1,66 @@
+package notify
+
+import (
+	"chatgpt/config"
+	"chatgpt/models"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+const (
+	ChannelLog     = "log"
+	ChannelWebhook = "webhook"
+	ChannelPush    = "push"
+)
+
+// New builds the notifier of the configured channels, only log if none are set.
+// Push goes to the registered devices of the user through push.
+func New(config *config.Config, push models.Notifier) (models.Notifier, error) {
+	channels := config.Notifiers
+	if len(channels) == 0 {
+		channels = []string{ChannelLog}
+	}
+
+	var notifiers Multi
+	for _, channel := range channels {
+		switch channel {
+		case ChannelLog:
+			notifiers = append(notifiers, LogNotifier{})
+		case ChannelWebhook:
+			if config.NotifyWebhookUrl == "" {
+				return nil, errors.New("notify: webhook url is not set")
+			}
+			notifiers = append(notifiers, NewWebhookNotifier(config.NotifyWebhookUrl, config.NotifyWebhookSecret))
+		case ChannelPush:
+			notifiers = append(notifiers, push)
+		default:
+			return nil, fmt.Errorf("notify: unknown channel %q", channel)
+		}
+	}
+
+	return notifiers, nil
+}
+
+// Multi delivers the notification through every notifier and returns the joined errors.
+type Multi []models.Notifier
+
+func (m Multi) Notify(ctx context.Context, notification models.Notification) error {
+	var errs []error
+	for _, n := range m {
+		err := n.Notify(ctx, notification)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// LogNotifier only writes notifications to the log, for development.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(ctx context.Context, notification models.Notification) error {
+	slog.InfoContext(ctx, "notify", "user", notification.UserId, "title", notification.Title)
+	return nil
+}
@@ -0,0 +1,56 @@
+package push
+
+import (
+	"context"
+	"sync"
+)
+
+// fakeSentLimit is the count of the latest messages the fake keeps.
+const fakeSentLimit = 100
+
+type Sent struct {
+	Token   string
+	Message Message
+}
+
+// Fake records the latest messages instead of sending them, for local runs and tests without network.
+// Tokens marked invalid are rejected like the real provider does.
+type Fake struct {
+	mu      sync.Mutex
+	sent    []Sent
+	invalid map[string]bool
+}
+
+func NewFake() *Fake {
+	return &Fake{invalid: make(map[string]bool)}
+}
+
+func (f *Fake) Send(ctx context.Context, token string, message Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.invalid[token] {
+		return ErrInvalidToken
+	}
+	if len(f.sent) == fakeSentLimit {
+		f.sent = append(f.sent[:0], f.sent[1:]...)
+	}
+	f.sent = append(f.sent, Sent{token, message})
+	return nil
+}
+
+// Invalidate makes next sends to the token fail with ErrInvalidToken.
+func (f *Fake) Invalidate(token string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.invalid[token] = true
+}
+
+// Sent returns the recorded messages, oldest first.
+func (f *Fake) Sent() []Sent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]Sent(nil), f.sent...)
+}
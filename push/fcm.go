@@ -0,0 +1,41 @@
+package push
+
+import (
+	f "chatgpt/auth/firebase"
+	"context"
+	"firebase.google.com/go/v4/messaging"
+)
+
+// FCMSender sends through Firebase Cloud Messaging with the app service account.
+type FCMSender struct {
+	client *messaging.Client
+}
+
+func NewFCMSender(ctx context.Context, credentialsPath string) (*FCMSender, error) {
+	app, err := f.NewApp(ctx, credentialsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := app.Messaging(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FCMSender{client}, nil
+}
+
+func (s *FCMSender) Send(ctx context.Context, token string, message Message) error {
+	_, err := s.client.Send(ctx, &messaging.Message{
+		Token: token,
+		Notification: &messaging.Notification{
+			Title: message.Title,
+			Body:  message.Body,
+		},
+		Data: message.Data,
+	})
+	if messaging.IsUnregistered(err) || messaging.IsSenderIDMismatch(err) {
+		return ErrInvalidToken
+	}
+	return err
+}
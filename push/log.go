@@ -0,0 +1,14 @@
+package push
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LogSender only writes pushes to the log, for development and deployments without a provider.
+type LogSender struct{}
+
+func (LogSender) Send(ctx context.Context, token string, message Message) error {
+	slog.InfoContext(ctx, "push", "title", message.Title, "locale", message.Locale)
+	return nil
+}
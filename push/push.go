@@ -0,0 +1,119 @@
+package push
+
+import (
+	"chatgpt/config"
+	"chatgpt/models"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+const (
+	SenderLog  = "log"
+	SenderFCM  = "fcm"
+	SenderFake = "fake"
+)
+
+// ErrInvalidToken is returned by senders when the device token is expired or unknown to the provider.
+var ErrInvalidToken = errors.New("push: invalid device token")
+
+type Message struct {
+	Title  string
+	Body   string
+	Locale string
+	Data   map[string]string
+}
+
+// Sender delivers a message to one device token of its provider.
+type Sender interface {
+	Send(ctx context.Context, token string, message Message) error
+}
+
+// Service pushes to every registered device of the user and prunes devices with invalid tokens.
+type Service struct {
+	Db      models.DbClient
+	Senders map[string]Sender
+}
+
+// New builds the service with the configured sender, the log one if none is set.
+// FCM delivers to both platforms, APNs tokens are kept until an APNs sender is added.
+func New(ctx context.Context, config *config.Config, db models.DbClient) (*Service, error) {
+	var sender Sender
+	switch config.PushSender {
+	case SenderLog, "":
+		sender = LogSender{}
+	case SenderFCM:
+		fcm, err := NewFCMSender(ctx, config.FirebaseCredentialsPath)
+		if err != nil {
+			return nil, err
+		}
+		sender = fcm
+	case SenderFake:
+		sender = NewFake()
+	default:
+		return nil, fmt.Errorf("push: unknown sender %q", config.PushSender)
+	}
+
+	return &Service{
+		Db:      db,
+		Senders: map[string]Sender{models.ProviderFCM: sender},
+	}, nil
+}
+
+// Notify implements models.Notifier.
+func (s *Service) Notify(ctx context.Context, notification models.Notification) error {
+	data := make(map[string]string, len(notification.Data)+1)
+	for k, v := range notification.Data {
+		data[k] = v
+	}
+	if notification.Conversation != "" {
+		data["conversation"] = notification.Conversation
+	}
+
+	return s.Send(ctx, notification.UserId.String(), Message{
+		Title: notification.Title,
+		Body:  notification.Text,
+		Data:  data,
+	})
+}
+
+// Send pushes the message to all devices of the user in their locale.
+func (s *Service) Send(ctx context.Context, userId string, message Message) error {
+	var filter models.FilterParams
+	filter.Filter = fmt.Sprintf(`user_id = '%v'`, userId)
+
+	var devices []models.Device
+	err := s.Db.Get(ctx, filter, &devices)
+	if err != nil {
+		return models.AllowErrNotFound(err)
+	}
+
+	var errs []error
+	for _, device := range devices {
+		sender, ok := s.Senders[device.Provider]
+		if !ok {
+			continue
+		}
+
+		message.Locale = device.Locale
+		err = sender.Send(ctx, device.Token, message)
+		if errors.Is(err, ErrInvalidToken) {
+			s.prune(ctx, device)
+		} else if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *Service) prune(ctx context.Context, device models.Device) {
+	var filter models.FilterParams
+	filter.Filter = fmt.Sprintf(`id = '%v'`, device.Id.String())
+
+	err := s.Db.Delete(ctx, filter, &models.Device{})
+	if models.AllowErrNotFound(err) != nil {
+		slog.ErrorContext(ctx, "prune device", "device", device.Id, "error", err)
+	}
+}
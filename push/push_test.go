@@ -0,0 +1,154 @@
+package push
+
+import (
+	"chatgpt/config"
+	"chatgpt/models"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// devicesDb serves the devices of every user and records the deleted rows.
+type devicesDb struct {
+	models.DbClient
+	devices []models.Device
+	deleted []string
+}
+
+func (db *devicesDb) Get(ctx context.Context, params models.FilterParams, out interface{}) error {
+	if len(db.devices) == 0 {
+		return errors.New(models.DB_ERROR_NOT_FOUND)
+	}
+	*out.(*[]models.Device) = append([]models.Device(nil), db.devices...)
+	return nil
+}
+
+func (db *devicesDb) Delete(ctx context.Context, params models.FilterParams, input interface{}) error {
+	db.deleted = append(db.deleted, params.Filter)
+	return nil
+}
+
+func device(token string, provider string, locale string) models.Device {
+	return models.Device{Id: uuid.New(), Token: token, Provider: provider, Locale: locale}
+}
+
+func TestSendPrunesInvalidTokens(t *testing.T) {
+	valid := device("valid", models.ProviderFCM, "ru")
+	expired := device("expired", models.ProviderFCM, "en")
+	db := &devicesDb{devices: []models.Device{valid, expired}}
+
+	fake := NewFake()
+	fake.Invalidate(expired.Token)
+	service := &Service{Db: db, Senders: map[string]Sender{models.ProviderFCM: fake}}
+
+	err := service.Send(context.Background(), uuid.NewString(), Message{Title: "title", Body: "body"})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	sent := fake.Sent()
+	if len(sent) != 1 || sent[0].Token != valid.Token {
+		t.Fatalf("sent %+v, want one message to %s", sent, valid.Token)
+	}
+	if sent[0].Message.Locale != valid.Locale {
+		t.Errorf("locale %q, want %q", sent[0].Message.Locale, valid.Locale)
+	}
+
+	if len(db.deleted) != 1 || !strings.Contains(db.deleted[0], expired.Id.String()) {
+		t.Errorf("deleted %v, want the expired device only", db.deleted)
+	}
+}
+
+func TestSendSkipsProvidersWithoutSender(t *testing.T) {
+	db := &devicesDb{devices: []models.Device{device("apns", models.ProviderAPNs, "en")}}
+
+	fake := NewFake()
+	service := &Service{Db: db, Senders: map[string]Sender{models.ProviderFCM: fake}}
+
+	err := service.Send(context.Background(), uuid.NewString(), Message{Title: "title"})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if len(fake.Sent()) != 0 || len(db.deleted) != 0 {
+		t.Errorf("sent %v and deleted %v, want the device kept without pushes", fake.Sent(), db.deleted)
+	}
+}
+
+func TestSendWithoutDevices(t *testing.T) {
+	service := &Service{Db: &devicesDb{}, Senders: map[string]Sender{models.ProviderFCM: NewFake()}}
+
+	err := service.Send(context.Background(), uuid.NewString(), Message{Title: "title"})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+}
+
+func TestNotifyAddsConversation(t *testing.T) {
+	db := &devicesDb{devices: []models.Device{device("token", models.ProviderFCM, "en")}}
+
+	fake := NewFake()
+	service := &Service{Db: db, Senders: map[string]Sender{models.ProviderFCM: fake}}
+
+	err := service.Notify(context.Background(), models.Notification{
+		UserId:       uuid.New(),
+		Title:        "title",
+		Text:         "text",
+		Conversation: "thread_1",
+		Data:         map[string]string{"message": "msg_1"},
+	})
+	if err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+
+	sent := fake.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("sent %d messages, want 1", len(sent))
+	}
+	message := sent[0].Message
+	if message.Body != "text" || message.Data["conversation"] != "thread_1" || message.Data["message"] != "msg_1" {
+		t.Errorf("message %+v, want the text, conversation and data", message)
+	}
+}
+
+func TestFakeKeepsLatest(t *testing.T) {
+	fake := NewFake()
+	for i := 0; i <= fakeSentLimit; i++ {
+		_ = fake.Send(context.Background(), strconv.Itoa(i), Message{})
+	}
+
+	sent := fake.Sent()
+	if len(sent) != fakeSentLimit {
+		t.Fatalf("kept %d messages, want %d", len(sent), fakeSentLimit)
+	}
+	if sent[0].Token != "1" || sent[len(sent)-1].Token != strconv.Itoa(fakeSentLimit) {
+		t.Errorf("kept %s to %s, want the latest", sent[0].Token, sent[len(sent)-1].Token)
+	}
+}
+
+func TestNewSender(t *testing.T) {
+	for _, sender := range []string{"", SenderLog} {
+		service, err := New(context.Background(), &config.Config{PushSender: sender}, &devicesDb{})
+		if err != nil {
+			t.Fatalf("sender %q: %v", sender, err)
+		}
+		if _, ok := service.Senders[models.ProviderFCM].(LogSender); !ok {
+			t.Errorf("sender %q is %T, want LogSender", sender, service.Senders[models.ProviderFCM])
+		}
+	}
+
+	service, err := New(context.Background(), &config.Config{PushSender: SenderFake}, &devicesDb{})
+	if err != nil {
+		t.Fatalf("fake sender: %v", err)
+	}
+	if _, ok := service.Senders[models.ProviderFCM].(*Fake); !ok {
+		t.Errorf("fake sender is %T, want *Fake", service.Senders[models.ProviderFCM])
+	}
+
+	_, err = New(context.Background(), &config.Config{PushSender: "apns"}, &devicesDb{})
+	if err == nil {
+		t.Error("unknown sender is accepted")
+	}
+}
@@ -0,0 +1,78 @@
+package search
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+)
+
+// Text search configurations of stored messages.
+const (
+	Russian = "russian"
+	English = "english"
+)
+
+var Configs = []string{Russian, English}
+
+// ts_headline marks matches with private use characters, they are replaced by <mark> after the text is escaped.
+const (
+	startSel = "\uE000"
+	stopSel  = "\uE001"
+)
+
+var headlineOptions = fmt.Sprintf(`StartSel="%v", StopSel="%v", MaxWords=30, MinWords=10, MaxFragments=2`, startSel, stopSel)
+
+// Language picks the text search configuration by the dominant alphabet of the text.
+func Language(text string) string {
+	var cyrillic, latin int
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		}
+	}
+
+	if cyrillic > 0 && cyrillic >= latin {
+		return Russian
+	}
+	return English
+}
+
+// Query selects hits of the user's messages matching the query with a snippet, by relevance and newest first.
+// Each row is compared with the query parsed in every configuration, so it matches by its own language.
+// The user input is bound to the placeholders of the returned SQL, the snippets need Highlight.
+func Query(userId string, query string, limit int, offset int) (string, map[string]interface{}) {
+	args := map[string]interface{}{
+		"user":    userId,
+		"query":   query,
+		"options": headlineOptions,
+		"limit":   limit,
+		"offset":  offset,
+	}
+
+	conditions := make([]string, 0, len(Configs))
+	for i, config := range Configs {
+		name := fmt.Sprintf("config%v", i)
+		args[name] = config
+		conditions = append(conditions, fmt.Sprintf(
+			`(language = cast(@%v as regconfig) and search @@ websearch_to_tsquery(cast(@%v as regconfig), @query))`, name, name))
+	}
+
+	sql := fmt.Sprintf(`select id as message_id, conversation, role, created_at,
+		ts_headline(language, text, websearch_to_tsquery(language, @query), @options) as snippet
+	from messages
+	where user_id = @user and (%v)
+	order by ts_rank(search, websearch_to_tsquery(language, @query)) desc, created_at desc
+	limit @limit offset @offset`, strings.Join(conditions, " or "))
+
+	return sql, args
+}
+
+// Highlight escapes the snippet for HTML and marks the matches with <mark>.
+func Highlight(snippet string) string {
+	snippet = html.EscapeString(snippet)
+	return strings.NewReplacer(startSel, "<mark>", stopSel, "</mark>").Replace(snippet)
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	StatusOk       = "ok"
+	StatusFail     = "fail"
+	StatusDraining = "draining"
+
+	readyTimeout = 2 * time.Second
+
+	// DefaultOpenAiProbeSeconds is how long an OpenAI probe result is reused, the probe is a paid API call.
+	DefaultOpenAiProbeSeconds = 60
+)
+
+type Check struct {
+	Status string `json:"status"`
+	// Latency of the check in milliseconds.
+	Latency float64 `json:"latency"`
+	// Critical checks fail the readiness, others are only reported.
+	Critical  bool      `json:"critical"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+type HealthResponse struct {
+	Status string           `json:"status"`
+	Checks map[string]Check `json:"checks,omitempty"`
+}
+
+// openAiProbe caches the last OpenAI reachability check.
+type openAiProbe struct {
+	mu    sync.Mutex
+	check Check
+}
+
+// runCheck pings the dependency, errors are logged and not returned, the endpoint is public.
+func runCheck(ctx context.Context, name string, critical bool, ping func(context.Context) error) Check {
+	ctx, cancel := context.WithTimeout(ctx, readyTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := ping(ctx)
+
+	check := Check{
+		Status:    StatusOk,
+		Latency:   float64(time.Since(start).Microseconds()) / 1000,
+		Critical:  critical,
+		CheckedAt: start.UTC(),
+	}
+	if err != nil {
+		check.Status = StatusFail
+		slog.WarnContext(ctx, "readiness check", "dependency", name, "error", err)
+	}
+	return check
+}
+
+// SetDraining makes the readiness fail, so load balancers stop routing new requests before shutdown.
+func (s *Server) SetDraining() {
+	s.draining.Store(true)
+}
+
+func (s *Server) Draining() bool {
+	return s.draining.Load()
+}
+
+// Healthz godoc
+//
+//	@Summary		Liveness
+//	@Description	the process is up and serving requests, dependencies are not checked
+//	@Tags			health
+//	@Produce		json
+//	@Success		200	{object}	HealthResponse
+//	@Router			/healthz [get]
+func (s *Server) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, HealthResponse{Status: StatusOk})
+}
+
+// Readyz godoc
+//
+//	@Summary		Readiness
+//	@Description	checks Postgres and Redis, and OpenAI if enabled; OpenAI is reported but does not fail the readiness
+//	@Tags			health
+//	@Produce		json
+//	@Success		200	{object}	HealthResponse
+//	@Failure		503	{object}	HealthResponse
+//	@Router			/readyz [get]
+func (s *Server) Readyz(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	if s.Draining() {
+		c.JSON(http.StatusServiceUnavailable, HealthResponse{Status: StatusDraining})
+		return
+	}
+
+	checks := map[string]func() Check{
+		"db":    func() Check { return runCheck(ctx, "db", true, s.Db.PingClient) },
+		"cache": func() Check { return runCheck(ctx, "cache", true, s.Cache.PingClient) },
+	}
+	if s.Configuration.ReadyOpenAiProbe {
+		checks["openai"] = func() Check { return s.probeOpenAi(ctx) }
+	}
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	response := HealthResponse{Status: StatusOk, Checks: make(map[string]Check, len(checks))}
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func() Check) {
+			defer wg.Done()
+			result := check()
+
+			mu.Lock()
+			defer mu.Unlock()
+			response.Checks[name] = result
+			if result.Critical && result.Status != StatusOk {
+				response.Status = StatusFail
+			}
+		}(name, check)
+	}
+	wg.Wait()
+
+	status := http.StatusOK
+	if response.Status != StatusOk {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, response)
+}
+
+func (s *Server) probeOpenAi(ctx context.Context) Check {
+	ttl := time.Duration(s.Configuration.ReadyOpenAiProbeSeconds) * time.Second
+	if ttl <= 0 {
+		ttl = DefaultOpenAiProbeSeconds * time.Second
+	}
+
+	s.openAi.mu.Lock()
+	defer s.openAi.mu.Unlock()
+
+	if time.Since(s.openAi.check.CheckedAt) < ttl {
+		return s.openAi.check
+	}
+
+	s.openAi.check = runCheck(ctx, "openai", false, s.AI.Ping)
+	return s.openAi.check
+}
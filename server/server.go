@@ -5,12 +5,20 @@ import (
 	"chatgpt/api/middleware"
 	f "chatgpt/auth/firebase"
 	"chatgpt/config"
+	"chatgpt/flags"
+	"chatgpt/lifecycle"
+	"chatgpt/metrics"
 	"chatgpt/models"
+	"chatgpt/push"
+	"chatgpt/settings"
+	"chatgpt/tracing"
 	"context"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	swaggerFiles "github.com/swaggo/files"
-	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"log/slog"
+	"slices"
+	"sync/atomic"
 	"time"
 )
 
@@ -21,32 +29,82 @@ type Server struct {
 	Cache         models.CacheClient
 	AI            *ai.AI
 	Firebase      *f.FirebaseAuthenticator
+	Push          *push.Service
+	Logger        *slog.Logger
+	Settings      *settings.Manager
+	Flags         *flags.Service
+	Lifecycle     *lifecycle.Manager
+
+	draining   atomic.Bool
+	openAi     openAiProbe
+	versions   map[int]*gin.RouterGroup
+	deprecated *gin.RouterGroup
+	swaggerUIs map[int]gin.HandlerFunc
 }
 
-func NewApiServer(config *config.Config, db models.DbClient, cache models.CacheClient, ai *ai.AI, firebase *f.FirebaseAuthenticator) *Server {
+func NewApiServer(config *config.Config, db models.DbClient, cache models.CacheClient, ai *ai.AI, firebase *f.FirebaseAuthenticator, push *push.Service, logger *slog.Logger, settings *settings.Manager, flags *flags.Service, lifecycle *lifecycle.Manager) *Server {
 	return &Server{
 		Configuration: config,
-		Router:        gin.Default(),
+		Router:        gin.New(),
 		Db:            db,
 		Cache:         cache,
 		AI:            ai,
 		Firebase:      firebase,
+		Push:          push,
+		Logger:        logger,
+		Settings:      settings,
+		Flags:         flags,
+		Lifecycle:     lifecycle,
 	}
 }
 
 func (s *Server) Init(ctx context.Context) {
-	s.Router.Use(cors.New(cors.Config{
-		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD"},
-		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization"},
-		AllowOrigins:     []string{"*"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	}),
+	s.Router.Use(gin.Recovery(),
+		otelgin.Middleware(tracing.ServiceName(s.Configuration)),
+		middleware.RequestId(),
+		middleware.Logger(s.Logger),
+		middleware.Metrics(),
+		middleware.SecurityHeaders(s.Configuration.HstsMaxAgeSeconds, s.Configuration.FrameOptions),
+		cors.New(cors.Config{
+			AllowMethods:     s.Configuration.CorsMethods,
+			AllowHeaders:     append(slices.Clone(s.Configuration.CorsHeaders), middleware.RequestIdHeader, middleware.SessionHeader, middleware.IdempotencyKeyHeader),
+			ExposeHeaders:    append(slices.Clone(s.Configuration.CorsExposeHeaders), middleware.RequestIdHeader, "Deprecation", "Sunset", "Link", middleware.IdempotentReplayedHeader),
+			AllowOriginFunc:  s.allowOrigin,
+			AllowCredentials: s.Configuration.CorsAllowCredentials,
+			MaxAge:           time.Duration(s.Configuration.CorsMaxAgeSeconds) * time.Second,
+		}),
 		middleware.JSONMiddleware(),
-		middleware.ErrorHandler())
+		middleware.Features(s.Flags),
+		middleware.ErrorHandler(s.Logger))
 
 	s.Router.GET("/", s.HealthCheck)
-	s.Router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	s.Router.GET("/healthz", s.Healthz)
+	s.Router.GET("/readyz", s.Readyz)
+	s.initVersions()
+
+	// With a separate metrics address they are served by metrics.Serve instead.
+	if s.Configuration.MetricsAddr == "" {
+		s.Router.GET("/metrics", gin.WrapH(metrics.Handler(s.Configuration.MetricsToken)))
+	}
+}
+
+// Detach returns a context of the request that is not cancelled when the client disconnects, see
+// lifecycle.Manager.Detach, done has to be called once the work is over.
+func (s *Server) Detach(ctx context.Context) (context.Context, func()) {
+	if s.Lifecycle == nil {
+		return context.WithCancel(context.WithoutCancel(ctx))
+	}
+	return s.Lifecycle.Detach(ctx)
+}
+
+// allowOrigin checks the origin against the current settings, so CORS changes apply without a restart.
+// With credentials only listed origins pass, the middleware echoes the origin, "*" would hand cookies to any site.
+func (s *Server) allowOrigin(origin string) bool {
+	origins := s.Settings.Current().CorsOrigins
+	if slices.Contains(origins, origin) {
+		return true
+	}
+	return !s.Configuration.CorsAllowCredentials && slices.Contains(origins, "*")
 }
 
 func (s *Server) HealthCheck(c *gin.Context) {
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"chatgpt/api/middleware"
+	"chatgpt/config"
+	"chatgpt/docs"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+	"github.com/swaggo/swag"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultVersion is served at the root, deprecated, and shown by the swagger UI if no version is asked for.
+const DefaultVersion = 1
+
+var (
+	// Versions of the API, each is served under /v<version>.
+	Versions = []int{1, 2}
+	// Unversioned paths are served at the root only, they are for probes and not part of the API.
+	Unversioned = []string{"/healthz", "/readyz"}
+)
+
+var swaggerVersion = regexp.MustCompile(`^/v([0-9]+)(/.*)?$`)
+
+// initVersions creates the route groups of the versions and the deprecated root group.
+func (s *Server) initVersions() {
+	s.versions = make(map[int]*gin.RouterGroup, len(Versions))
+	for _, version := range Versions {
+		s.versions[version] = s.Router.Group(VersionPrefix(version))
+	}
+
+	var deprecatedAt, sunsetAt time.Time
+	if s.Configuration.UnversionedDeprecatedAt != "" {
+		deprecatedAt, _ = time.Parse(config.DateLayout, s.Configuration.UnversionedDeprecatedAt)
+	}
+	if s.Configuration.UnversionedSunsetAt != "" {
+		sunsetAt, _ = time.Parse(config.DateLayout, s.Configuration.UnversionedSunsetAt)
+	}
+	s.deprecated = s.Router.Group("", middleware.Deprecated(VersionPrefix(DefaultVersion), deprecatedAt, sunsetAt))
+
+	s.swaggerUIs = make(map[int]gin.HandlerFunc, len(Versions))
+	for _, version := range Versions {
+		name := VersionPrefix(version)[1:]
+		swag.Register(name, versionedSpec(version))
+		s.swaggerUIs[version] = ginSwagger.WrapHandler(swaggerFiles.Handler, ginSwagger.InstanceName(name))
+	}
+	s.Router.GET("/swagger/*any", s.swagger)
+}
+
+func VersionPrefix(version int) string {
+	return "/v" + strconv.Itoa(version)
+}
+
+// Handle registers routes served the same way by every version, and at the root for apps released before
+// versioning. Routes that differ between versions are registered on Version instead.
+func (s *Server) Handle(register func(r *gin.RouterGroup)) {
+	for _, version := range Versions {
+		register(s.versions[version])
+	}
+	register(s.deprecated)
+}
+
+// Version is the route group of a single version, the godoc of its routes lists the version in @x-versions.
+func (s *Server) Version(version int) *gin.RouterGroup {
+	group, ok := s.versions[version]
+	if !ok {
+		panic(fmt.Sprintf("unknown API version %v", version))
+	}
+	return group
+}
+
+// swagger serves the UI and the spec of a version under /swagger/v<version>/. Other paths are redirected
+// to the version of the version query parameter or the Accept-Version header, or to the default one.
+func (s *Server) swagger(c *gin.Context) {
+	path := c.Param("any")
+	if match := swaggerVersion.FindStringSubmatch(path); match != nil {
+		version, _ := strconv.Atoi(match[1])
+		if ui, ok := s.swaggerUIs[version]; ok {
+			ui(c)
+			return
+		}
+	}
+
+	version := DefaultVersion
+	for _, asked := range []string{c.Query("version"), c.GetHeader("Accept-Version")} {
+		n, err := strconv.Atoi(strings.TrimPrefix(asked, "v"))
+		if err == nil && slices.Contains(Versions, n) {
+			version = n
+			break
+		}
+	}
+
+	if path == "/" || path == "" {
+		path = "/index.html"
+	}
+	c.Redirect(http.StatusFound, "/swagger"+VersionPrefix(version)+path)
+}
+
+// versionedSpec is the generated spec with the paths of a version. It is built on every read,
+// so changes of docs.SwaggerInfo made after the start, like the schemes, apply.
+type versionedSpec int
+
+func (v versionedSpec) ReadDoc() string {
+	doc, err := Spec(int(v))
+	if err != nil {
+		return docs.SwaggerInfo.ReadDoc()
+	}
+	return doc
+}
+
+// Spec is the swagger spec of the version. The annotations document the paths without a version,
+// they are prefixed here except the Unversioned ones. Operations of routes registered on Version
+// list their versions in the annotation @x-versions, e.g. [2], others are in every version.
+func Spec(version int) (string, error) {
+	var spec map[string]any
+	err := json.Unmarshal([]byte(docs.SwaggerInfo.ReadDoc()), &spec)
+	if err != nil {
+		return "", err
+	}
+
+	paths, _ := spec["paths"].(map[string]any)
+	versioned := make(map[string]any, len(paths))
+	for path, operations := range paths {
+		kept := make(map[string]any)
+		for method, operation := range operations.(map[string]any) {
+			if servedBy(operation, version) {
+				kept[method] = operation
+			}
+		}
+		if len(kept) == 0 {
+			continue
+		}
+
+		if !slices.Contains(Unversioned, path) {
+			path = VersionPrefix(version) + path
+		}
+		versioned[path] = kept
+	}
+	spec["paths"] = versioned
+
+	if info, ok := spec["info"].(map[string]any); ok {
+		info["version"] = fmt.Sprintf("%v.0", version)
+	}
+
+	doc, err := json.Marshal(spec)
+	return string(doc), err
+}
+
+func servedBy(operation any, version int) bool {
+	versions, ok := operation.(map[string]any)["x-versions"].([]any)
+	if !ok {
+		return true
+	}
+	return slices.Contains(versions, any(float64(version)))
+}
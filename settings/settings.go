@@ -0,0 +1,240 @@
+package settings
+
+import (
+	"chatgpt/config"
+	"chatgpt/models"
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const (
+	// Key of the stored settings, they replace the settings of the config file while present.
+	Key = "settings"
+	// Channel tells every replica to reload after the stored settings changed.
+	Channel = "settings"
+
+	// resyncInterval reloads the stored settings in case a replica missed a message.
+	resyncInterval = time.Minute
+	retryInterval  = 5 * time.Second
+)
+
+type stored struct {
+	models.Settings
+	Version   int64     `json:"version"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Manager keeps the effective settings. They come from the config file, or from Redis if they are stored there,
+// and are reloaded on SIGHUP, on a message of the settings channel and periodically.
+// Each reload builds the settings completely and swaps them at once, then calls the OnChange funcs.
+type Manager struct {
+	cache   models.CacheClient
+	reread  func() (*config.Config, error)
+	current atomic.Pointer[models.EffectiveSettings]
+
+	// mu serializes reloads, so the OnChange funcs see the settings in order.
+	mu          sync.Mutex
+	base        models.Settings
+	baseChanged bool
+	onChange    []func(ctx context.Context, settings models.Settings) error
+}
+
+// New starts with the settings of the configuration, reread loads the config file again on SIGHUP.
+func New(cache models.CacheClient, configuration *config.Config, reread func() (*config.Config, error)) *Manager {
+	m := &Manager{
+		cache:  cache,
+		reread: reread,
+		base:   FromConfig(configuration),
+	}
+	m.current.Store(&models.EffectiveSettings{
+		Settings: m.base,
+		Source:   models.SettingsSourceConfig,
+		LoadedAt: time.Now().UTC(),
+	})
+	return m
+}
+
+// FromConfig picks the reloadable settings of the configuration.
+func FromConfig(configuration *config.Config) models.Settings {
+	return models.Settings{
+		Personas:       configuration.Personas,
+		DefaultPersona: configuration.DefaultPersona,
+		CorsOrigins:    configuration.CorsOrigins,
+	}
+}
+
+// Current returns the effective settings, safe to call on every request.
+func (m *Manager) Current() models.EffectiveSettings {
+	return *m.current.Load()
+}
+
+// OnChange registers a func applying the settings, it is called after every reload.
+func (m *Manager) OnChange(apply func(ctx context.Context, settings models.Settings) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = append(m.onChange, apply)
+}
+
+// Reload loads the stored settings, or takes the config ones if nothing is stored, and applies them if they changed.
+func (m *Manager) Reload(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	effective := models.EffectiveSettings{
+		Settings: m.base,
+		Source:   models.SettingsSourceConfig,
+		LoadedAt: time.Now().UTC(),
+	}
+
+	var s stored
+	err := m.cache.GetHash(ctx, Key, &s)
+	if err == nil {
+		updatedAt := s.UpdatedAt
+		effective.Settings = s.Settings
+		effective.Source = models.SettingsSourceRedis
+		effective.Version = s.Version
+		effective.UpdatedAt = &updatedAt
+	} else if !errors.Is(err, redis.Nil) {
+		return err
+	}
+
+	previous := m.current.Load()
+	if previous.Source == effective.Source && previous.Version == effective.Version && !m.baseChanged {
+		return nil
+	}
+
+	m.baseChanged = false
+	m.current.Store(&effective)
+	slog.InfoContext(ctx, "settings applied", "source", effective.Source, "version", effective.Version)
+
+	var errs []error
+	for _, apply := range m.onChange {
+		errs = append(errs, apply(ctx, effective.Settings))
+	}
+	return errors.Join(errs...)
+}
+
+// Save stores the settings for all replicas and tells them to reload.
+func (m *Manager) Save(ctx context.Context, settings models.Settings) error {
+	now := time.Now().UTC()
+	err := m.cache.SetHash(ctx, Key, stored{Settings: settings, Version: now.UnixNano(), UpdatedAt: now}, 0)
+	if err != nil {
+		return err
+	}
+	return m.publish(ctx)
+}
+
+// Reset removes the stored settings, all replicas go back to the config ones.
+func (m *Manager) Reset(ctx context.Context) error {
+	err := m.cache.DeleteHash(ctx, Key)
+	if err != nil {
+		return err
+	}
+	return m.publish(ctx)
+}
+
+func (m *Manager) publish(ctx context.Context) error {
+	err := m.cache.PublishMsg(ctx, Channel, "reload")
+	if err != nil {
+		return err
+	}
+	return m.Reload(ctx)
+}
+
+// Run reloads the settings on SIGHUP, on messages of the settings channel and every resyncInterval until ctx is cancelled.
+func (m *Manager) Run(ctx context.Context) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	messages := make(chan struct{}, 1)
+	go m.listen(ctx, messages)
+
+	ticker := time.NewTicker(resyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			m.rereadConfig(ctx)
+		case <-messages:
+		case <-ticker.C:
+		}
+
+		err := m.Reload(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "reload settings", "error", err)
+		}
+	}
+}
+
+// rereadConfig takes the settings of the config file again, other changes of the file need a restart.
+func (m *Manager) rereadConfig(ctx context.Context) {
+	configuration, err := m.reread()
+	if err == nil {
+		err = configuration.Validate()
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "reread config", "error", err)
+		return
+	}
+
+	base := FromConfig(configuration)
+	err = base.Validate()
+	if err != nil {
+		slog.ErrorContext(ctx, "reread config", "error", err)
+		return
+	}
+
+	m.mu.Lock()
+	m.base = base
+	m.baseChanged = true
+	m.mu.Unlock()
+}
+
+// listen signals every message of the settings channel, it resubscribes after errors.
+func (m *Manager) listen(ctx context.Context, messages chan<- struct{}) {
+	for ctx.Err() == nil {
+		pubSub, err := m.cache.SubScribe(ctx, Channel)
+		if err != nil {
+			slog.ErrorContext(ctx, "subscribe settings", "error", err)
+			sleep(ctx, retryInterval)
+			continue
+		}
+
+		for {
+			_, err = pubSub.ReceiveMessage(ctx)
+			if err != nil {
+				break
+			}
+
+			select {
+			case messages <- struct{}{}:
+			default:
+			}
+		}
+
+		pubSub.Close()
+		if ctx.Err() == nil {
+			slog.ErrorContext(ctx, "receive settings", "error", err)
+			sleep(ctx, retryInterval)
+		}
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
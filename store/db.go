@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 )
 
@@ -35,6 +36,11 @@ func NewDB(config *config.Config, out *models.DbClient) error {
 		return err
 	}
 
+	err = registerDbMetrics(db)
+	if err != nil {
+		return err
+	}
+
 	conn := NewConn(db)
 	if out != nil && db != nil {
 		*out = conn
@@ -50,7 +56,10 @@ func (this *DbClientReal) CloseClient() error {
 	return sqlDb.Close()
 }
 
-func (this *DbClientReal) PingClient(ctx context.Context) error {
+func (this *DbClientReal) PingClient(ctx context.Context) (err error) {
+	ctx, span := startDbSpan(ctx, "ping", "")
+	defer func() { endDbSpan(span, err) }()
+
 	sqlDB, err := this.Db.DB()
 	if err != nil {
 		return err
@@ -58,8 +67,36 @@ func (this *DbClientReal) PingClient(ctx context.Context) error {
 	return sqlDB.PingContext(ctx)
 }
 
-func (this *DbClientReal) Select(ctx context.Context, table string, params models.FilterParams, out interface{}) error {
-	exec := this.Db.WithContext(ctx).Table(table).Select(params.Select).Where(params.Filter).Scan(out)
+func (this *DbClientReal) Migrate(ctx context.Context, tables ...interface{}) (err error) {
+	ctx, span := startDbSpan(ctx, "migrate", "")
+	defer func() { endDbSpan(span, err) }()
+
+	return this.Db.WithContext(ctx).AutoMigrate(tables...)
+}
+
+func (this *DbClientReal) Select(ctx context.Context, table string, params models.FilterParams, out interface{}) (err error) {
+	ctx, span := startDbSpan(ctx, "select", table)
+	defer func() { endDbSpan(span, err) }()
+
+	exec := this.Db.WithContext(ctx).Table(table).Select(params.Select).Where(params.Filter)
+	if params.Group != "" {
+		exec = exec.Group(params.Group)
+	}
+	exec = exec.Order(params.Orderings).Limit(params.ValidLimit()).Offset(params.Offset).Scan(out)
+	if exec.Error != nil {
+		return exec.Error
+	}
+	if exec.RowsAffected == 0 {
+		return errors.New(models.DB_ERROR_NOT_FOUND)
+	}
+	return nil
+}
+
+func (this *DbClientReal) Raw(ctx context.Context, out interface{}, query string, args ...interface{}) (err error) {
+	ctx, span := startDbSpan(ctx, "raw", "")
+	defer func() { endDbSpan(span, err) }()
+
+	exec := this.Db.WithContext(ctx).Raw(query, args...).Scan(out)
 	if exec.Error != nil {
 		return exec.Error
 	}
@@ -69,7 +106,10 @@ func (this *DbClientReal) Select(ctx context.Context, table string, params model
 	return nil
 }
 
-func (this *DbClientReal) Get(ctx context.Context, query models.FilterParams, out interface{}) error {
+func (this *DbClientReal) Get(ctx context.Context, query models.FilterParams, out interface{}) (err error) {
+	ctx, span := startDbSpan(ctx, "get", "")
+	defer func() { endDbSpan(span, err) }()
+
 	exec := this.Db.WithContext(ctx).Order(query.Orderings).Where(query.Filter).Limit(query.ValidLimit()).Find(out)
 
 	if exec.Error != nil {
@@ -81,7 +121,10 @@ func (this *DbClientReal) Get(ctx context.Context, query models.FilterParams, ou
 	return nil
 }
 
-func (this *DbClientReal) GetView(ctx context.Context, viewName string, params models.FilterParams, out interface{}) error {
+func (this *DbClientReal) GetView(ctx context.Context, viewName string, params models.FilterParams, out interface{}) (err error) {
+	ctx, span := startDbSpan(ctx, "get", viewName)
+	defer func() { endDbSpan(span, err) }()
+
 	exec := this.Db.WithContext(ctx).Table(viewName).Order(params.Orderings).Where(params.Filter).Limit(params.ValidLimit()).Offset(params.Offset).Find(out)
 	if exec.Error != nil {
 		return exec.Error
@@ -92,14 +135,20 @@ func (this *DbClientReal) GetView(ctx context.Context, viewName string, params m
 	return nil
 }
 
-func (this *DbClientReal) Create(ctx context.Context, input interface{}) error {
+func (this *DbClientReal) Create(ctx context.Context, input interface{}) (err error) {
+	ctx, span := startDbSpan(ctx, "create", "")
+	defer func() { endDbSpan(span, err) }()
+
 	if input == nil {
 		return errors.New("creation data is nil")
 	}
 	return this.Db.WithContext(ctx).Create(input).Error
 }
 
-func (d *DbClientReal) Update(ctx context.Context, params models.FilterParams, input interface{}) error {
+func (d *DbClientReal) Update(ctx context.Context, params models.FilterParams, input interface{}) (err error) {
+	ctx, span := startDbSpan(ctx, "update", "")
+	defer func() { endDbSpan(span, err) }()
+
 	if input == nil {
 		return errors.New("updated data is nil")
 	}
@@ -113,18 +162,61 @@ func (d *DbClientReal) Update(ctx context.Context, params models.FilterParams, i
 	return nil
 }
 
-func (d *DbClientReal) Upsert(ctx context.Context, params models.FilterParams, input interface{}) error {
+func (d *DbClientReal) Upsert(ctx context.Context, params models.FilterParams, input interface{}) (err error) {
+	ctx, span := startDbSpan(ctx, "upsert", "")
+	defer func() { endDbSpan(span, err) }()
+
 	if input == nil {
 		return errors.New("data is nil")
 	}
-	err := d.Update(ctx, params, input)
+	err = d.Update(ctx, params, input)
 	if models.IsErrNotFound(err) {
 		return d.Create(ctx, input)
 	}
 	return err
 }
 
-func (d *DbClientReal) Delete(ctx context.Context, params models.FilterParams, input interface{}) error {
+func (d *DbClientReal) Replace(ctx context.Context, params models.FilterParams, input interface{}) (err error) {
+	ctx, span := startDbSpan(ctx, "replace", "")
+	defer func() { endDbSpan(span, err) }()
+
+	if input == nil {
+		return errors.New("replaced data is nil")
+	}
+	return d.Db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		exec := tx.Model(input).Where(params.Filter).Select("*").Updates(input)
+		if exec.Error != nil {
+			return exec.Error
+		}
+		if exec.RowsAffected == 0 {
+			return errors.New(models.DB_ERROR_NOT_FOUND)
+		}
+		if exec.RowsAffected > 1 {
+			return fmt.Errorf("replace matched %d rows", exec.RowsAffected)
+		}
+		return nil
+	})
+}
+
+func (d *DbClientReal) UpsertOnConflict(ctx context.Context, input interface{}, columns ...string) (err error) {
+	ctx, span := startDbSpan(ctx, "upsert", "")
+	defer func() { endDbSpan(span, err) }()
+
+	if input == nil {
+		return errors.New("data is nil")
+	}
+
+	conflict := clause.OnConflict{UpdateAll: true}
+	for _, column := range columns {
+		conflict.Columns = append(conflict.Columns, clause.Column{Name: column})
+	}
+	return d.Db.WithContext(ctx).Clauses(conflict).Create(input).Error
+}
+
+func (d *DbClientReal) Delete(ctx context.Context, params models.FilterParams, input interface{}) (err error) {
+	ctx, span := startDbSpan(ctx, "delete", "")
+	defer func() { endDbSpan(span, err) }()
+
 	if input == nil {
 		return errors.New("delete data is nil")
 	}
@@ -0,0 +1,64 @@
+package store
+
+import (
+	"chatgpt/metrics"
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"net"
+)
+
+// registerDbMetrics counts failed queries of every kind, found nothing is a result and not an error.
+func registerDbMetrics(db *gorm.DB) error {
+	callbacks := db.Callback()
+	observe := func(operation string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+				metrics.StoreErrors.WithLabelValues(metrics.StoreDb, operation).Inc()
+			}
+		}
+	}
+
+	return errors.Join(
+		callbacks.Create().After("gorm:create").Register("metrics:create", observe("create")),
+		callbacks.Query().After("gorm:query").Register("metrics:query", observe("query")),
+		callbacks.Update().After("gorm:update").Register("metrics:update", observe("update")),
+		callbacks.Delete().After("gorm:delete").Register("metrics:delete", observe("delete")),
+		callbacks.Row().After("gorm:row").Register("metrics:row", observe("row")),
+		callbacks.Raw().After("gorm:raw").Register("metrics:raw", observe("raw")),
+	)
+}
+
+// redisMetrics counts failed Redis commands, a missing key is not an error.
+type redisMetrics struct{}
+
+func (redisMetrics) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := next(ctx, network, addr)
+		if err != nil {
+			metrics.StoreErrors.WithLabelValues(metrics.StoreRedis, "dial").Inc()
+		}
+		return conn, err
+	}
+}
+
+func (redisMetrics) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		err := next(ctx, cmd)
+		if err != nil && !errors.Is(err, redis.Nil) {
+			metrics.StoreErrors.WithLabelValues(metrics.StoreRedis, cmd.Name()).Inc()
+		}
+		return err
+	}
+}
+
+func (redisMetrics) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		err := next(ctx, cmds)
+		if err != nil && !errors.Is(err, redis.Nil) {
+			metrics.StoreErrors.WithLabelValues(metrics.StoreRedis, "pipeline").Inc()
+		}
+		return err
+	}
+}
@@ -12,17 +12,19 @@ import (
 
 type RedisClientReal struct {
 	Client         *redis.Client
-	PubSub         *redis.PubSub
 	IsEnablePubSub bool
 }
 
 func NewRedisConn(config *config.Config) *RedisClientReal {
+	client := redis.NewClient(&redis.Options{
+		Addr:     config.CacheHost,
+		Password: config.CachePass,
+		DB:       0,
+	})
+	client.AddHook(redisMetrics{})
+
 	return &RedisClientReal{
-		Client: redis.NewClient(&redis.Options{
-			Addr:     config.CacheHost,
-			Password: config.CachePass,
-			DB:       0,
-		}),
+		Client:         client,
 		IsEnablePubSub: true,
 	}
 }
@@ -41,7 +43,17 @@ func NewCacheClient(ctx context.Context, config *config.Config, out *models.Cach
 	return nil
 }
 
-func (this RedisClientReal) SetHash(ctx context.Context, key string, objectType interface{}, expTime time.Duration) error {
+func (this RedisClientReal) PingClient(ctx context.Context) (err error) {
+	ctx, span := startRedisSpan(ctx, "ping")
+	defer func() { endRedisSpan(span, err) }()
+
+	return this.Client.Ping(ctx).Err()
+}
+
+func (this RedisClientReal) SetHash(ctx context.Context, key string, objectType interface{}, expTime time.Duration) (err error) {
+	ctx, span := startRedisSpan(ctx, "set")
+	defer func() { endRedisSpan(span, err) }()
+
 	value, err := json.Marshal(objectType)
 	if err != nil {
 		return err
@@ -49,7 +61,21 @@ func (this RedisClientReal) SetHash(ctx context.Context, key string, objectType
 	return this.Client.Set(ctx, key, value, expTime).Err()
 }
 
-func (this RedisClientReal) GetHash(ctx context.Context, key string, out interface{}) error {
+func (this RedisClientReal) SetHashNX(ctx context.Context, key string, objectType interface{}, expTime time.Duration) (ok bool, err error) {
+	ctx, span := startRedisSpan(ctx, "setnx")
+	defer func() { endRedisSpan(span, err) }()
+
+	value, err := json.Marshal(objectType)
+	if err != nil {
+		return false, err
+	}
+	return this.Client.SetNX(ctx, key, value, expTime).Result()
+}
+
+func (this RedisClientReal) GetHash(ctx context.Context, key string, out interface{}) (err error) {
+	ctx, span := startRedisSpan(ctx, "get")
+	defer func() { endRedisSpan(span, err) }()
+
 	result, err := this.Client.Get(ctx, key).Bytes()
 
 	if err != nil {
@@ -58,7 +84,10 @@ func (this RedisClientReal) GetHash(ctx context.Context, key string, out interfa
 	return json.Unmarshal(result, &out)
 }
 
-func (this RedisClientReal) GetKeys(ctx context.Context, pattern string, out *[]string) error {
+func (this RedisClientReal) GetKeys(ctx context.Context, pattern string, out *[]string) (err error) {
+	ctx, span := startRedisSpan(ctx, "keys")
+	defer func() { endRedisSpan(span, err) }()
+
 	keys, err := this.Client.Keys(ctx, pattern).Result()
 	if err != nil {
 		return err
@@ -71,6 +100,39 @@ func (this RedisClientReal) GetKeys(ctx context.Context, pattern string, out *[]
 	return nil
 }
 
+func (this RedisClientReal) AddToSet(ctx context.Context, key string, expTime time.Duration, members ...string) (err error) {
+	ctx, span := startRedisSpan(ctx, "sadd")
+	defer func() { endRedisSpan(span, err) }()
+
+	values := make([]interface{}, len(members))
+	for i, member := range members {
+		values[i] = member
+	}
+
+	_, err = this.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SAdd(ctx, key, values...)
+		pipe.Expire(ctx, key, expTime)
+		return nil
+	})
+	return err
+}
+
+func (this RedisClientReal) GetSet(ctx context.Context, key string, out *[]string) (err error) {
+	ctx, span := startRedisSpan(ctx, "smembers")
+	defer func() { endRedisSpan(span, err) }()
+
+	members, err := this.Client.SMembers(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+
+	*out = append(*out, members...)
+	return nil
+}
+
 const (
 	ListStart = 0
 	ListEnd   = -1
@@ -84,7 +146,10 @@ func UnMarshalStruct(in interface{}, out interface{}) error {
 	return json.Unmarshal(bytes, out)
 }
 
-func (this RedisClientReal) GetList(ctx context.Context, list string, out interface{}) error {
+func (this RedisClientReal) GetList(ctx context.Context, list string, out interface{}) (err error) {
+	ctx, span := startRedisSpan(ctx, "lrange")
+	defer func() { endRedisSpan(span, err) }()
+
 	elements, err := this.Client.LRange(ctx, list, ListStart, ListEnd).Result()
 	if err != nil {
 		return err
@@ -107,7 +172,10 @@ func (this RedisClientReal) GetList(ctx context.Context, list string, out interf
 	return UnMarshalStruct(results, out)
 }
 
-func (this RedisClientReal) PushToList(ctx context.Context, key string, objectType interface{}) error {
+func (this RedisClientReal) PushToList(ctx context.Context, key string, objectType interface{}) (err error) {
+	ctx, span := startRedisSpan(ctx, "rpush")
+	defer func() { endRedisSpan(span, err) }()
+
 	value, err := json.Marshal(objectType)
 	if err != nil {
 		return err
@@ -115,45 +183,38 @@ func (this RedisClientReal) PushToList(ctx context.Context, key string, objectTy
 	return this.Client.RPush(ctx, key, value).Err()
 }
 
-func (this RedisClientReal) DeleteHash(ctx context.Context, key string) error {
+func (this RedisClientReal) DeleteHash(ctx context.Context, key string) (err error) {
+	ctx, span := startRedisSpan(ctx, "del")
+	defer func() { endRedisSpan(span, err) }()
+
 	return this.Client.Del(ctx, key).Err()
 }
 
-func (this RedisClientReal) PublishMsg(ctx context.Context, topic string, msg interface{}) error {
+func (this RedisClientReal) PublishMsg(ctx context.Context, topic string, msg interface{}) (err error) {
+	ctx, span := startRedisSpan(ctx, "publish")
+	defer func() { endRedisSpan(span, err) }()
+
 	if !this.IsEnablePubSub {
 		return nil
 	}
 	return this.Client.Publish(ctx, topic, msg).Err()
 }
 
-func (this *RedisClientReal) SubScribe(ctx context.Context, topics ...string) error {
-	if !this.IsEnablePubSub {
-		return nil
-	}
-	this.PubSub = this.Client.Subscribe(ctx, topics...)
-	_, err := this.PubSub.Receive(ctx)
-	return err
-}
+// SubScribe subscribes a connection of its own to the topics, the caller closes it.
+func (this RedisClientReal) SubScribe(ctx context.Context, topics ...string) (pubSub *redis.PubSub, err error) {
+	ctx, span := startRedisSpan(ctx, "subscribe")
+	defer func() { endRedisSpan(span, err) }()
 
-func (this *RedisClientReal) ReceiveMsg(ctx context.Context, out *redis.Message) error {
-	if this.PubSub == nil {
-		return errors.New("no subscriber is provided")
+	if !this.IsEnablePubSub {
+		return nil, errors.New("pub/sub is disabled")
 	}
-	msg, err := this.PubSub.ReceiveMessage(ctx)
+	pubSub = this.Client.Subscribe(ctx, topics...)
+	_, err = pubSub.Receive(ctx)
 	if err != nil {
-		return err
-	}
-	if out != nil && msg != nil {
-		*out = *msg
-	}
-	return nil
-}
-
-func (this RedisClientReal) CloseSub(ctx context.Context) error {
-	if !this.IsEnablePubSub {
-		return nil
+		pubSub.Close()
+		return nil, err
 	}
-	return this.PubSub.Unsubscribe(ctx)
+	return pubSub, nil
 }
 
 func (this RedisClientReal) CloseClient() error {
@@ -0,0 +1,47 @@
+package store
+
+import (
+	"chatgpt/models"
+	"chatgpt/tracing"
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("chatgpt/store")
+
+// startDbSpan starts a span of a DbClientReal method, table is empty if the model defines it.
+// Filters are not recorded, they contain user data.
+func startDbSpan(ctx context.Context, operation string, table string) (context.Context, trace.Span) {
+	attributes := []attribute.KeyValue{semconv.DBSystemPostgreSQL, semconv.DBOperation(operation)}
+	if table != "" {
+		attributes = append(attributes, semconv.DBSQLTable(table))
+	}
+	return tracer.Start(ctx, "db "+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+}
+
+// endDbSpan ends the span, found nothing is a result and not an error.
+func endDbSpan(span trace.Span, err error) {
+	if models.IsErrNotFound(err) {
+		err = nil
+	}
+	tracing.End(span, err)
+}
+
+// startRedisSpan starts a span of a RedisClientReal method. Keys are not recorded, some of them are tokens.
+func startRedisSpan(ctx context.Context, operation string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "redis "+operation, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemRedis, semconv.DBOperation(operation)))
+}
+
+// endRedisSpan ends the span, a missing key is not an error.
+func endRedisSpan(span trace.Span, err error) {
+	if errors.Is(err, redis.Nil) {
+		err = nil
+	}
+	tracing.End(span, err)
+}
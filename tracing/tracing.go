@@ -0,0 +1,89 @@
+package tracing
+
+import (
+	"chatgpt/config"
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+)
+
+const (
+	ExporterNone = "none"
+	ExporterOTLP = "otlp"
+
+	DefaultServiceName = "thera-api"
+)
+
+// New installs the W3C trace context propagator and, if enabled, a tracer provider
+// exporting spans over OTLP/HTTP. The returned func flushes the spans left on shutdown.
+func New(ctx context.Context, config *config.Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	switch config.TraceExporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", config.TraceExporter)
+	}
+
+	var options []otlptracehttp.Option
+	if config.TraceEndpoint != "" {
+		options = append(options, otlptracehttp.WithEndpoint(config.TraceEndpoint))
+	}
+	if config.TraceInsecure {
+		options = append(options, otlptracehttp.WithInsecure())
+	}
+
+	exporter, err := otlptracehttp.New(ctx, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(ServiceName(config))))
+	if err != nil {
+		return nil, err
+	}
+
+	sampler := sdktrace.AlwaysSample()
+	if config.TraceSampleRatio > 0 && config.TraceSampleRatio < 1 {
+		sampler = sdktrace.TraceIDRatioBased(config.TraceSampleRatio)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		slog.Warn("tracing", "error", err)
+	}))
+
+	return provider.Shutdown, nil
+}
+
+// ServiceName is the name spans of this service are reported under.
+func ServiceName(config *config.Config) string {
+	if config.TraceServiceName == "" {
+		return DefaultServiceName
+	}
+	return config.TraceServiceName
+}
+
+// End records the error on the span, if any, and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
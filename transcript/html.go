@@ -0,0 +1,66 @@
+package transcript
+
+import (
+	"html/template"
+	"io"
+	"time"
+)
+
+// htmlTemplate is laid out for printing, so the browser can save it as PDF.
+var htmlTemplate = template.Must(template.New("transcript").Parse(`<!DOCTYPE html>
+<html lang="{{.Locale}}">
+<head>
+<meta charset="utf-8">
+<title>{{.Labels.Title}}</title>
+<style>
+	body { font-family: Georgia, "Times New Roman", serif; max-width: 42em; margin: 2em auto; color: #222; line-height: 1.5; }
+	header { border-bottom: 1px solid #999; margin-bottom: 1.5em; }
+	.message { margin: 0 0 1.2em; page-break-inside: avoid; break-inside: avoid; }
+	.meta { font-size: .85em; color: #666; }
+	.role { font-weight: bold; color: #222; }
+	.text { white-space: pre-wrap; margin-top: .3em; }
+	.user .text { padding-left: .8em; border-left: 3px solid #bbb; }
+	@page { margin: 2cm; }
+	@media print { body { margin: 0; max-width: none; } }
+</style>
+</head>
+<body>
+<header>
+	<h1>{{.Labels.Title}}</h1>
+	<p class="meta">{{.Labels.Exported}}: {{.ExportedAt}}</p>
+</header>
+{{range .Messages}}<div class="message {{.Role}}">
+	<div class="meta"><span class="role">{{.Label}}</span> · {{.Time}}</div>
+	<div class="text">{{.Text}}</div>
+</div>
+{{end}}</body>
+</html>
+`))
+
+type htmlMessage struct {
+	Role  string
+	Label string
+	Time  string
+	Text  string
+}
+
+func renderHTML(w io.Writer, t Transcript, opts Options) error {
+	l := labels[t.Locale]
+
+	messages := make([]htmlMessage, 0, len(t.Messages))
+	for _, m := range t.Messages {
+		messages = append(messages, htmlMessage{
+			Role:  m.Role,
+			Label: role(l, m.Role),
+			Time:  formatTime(time.Unix(m.CreatedAt, 0), opts.Location),
+			Text:  m.Text,
+		})
+	}
+
+	return htmlTemplate.Execute(w, struct {
+		Locale     string
+		Labels     Labels
+		ExportedAt string
+		Messages   []htmlMessage
+	}{t.Locale, l, formatTime(t.ExportedAt, opts.Location), messages})
+}
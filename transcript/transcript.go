@@ -0,0 +1,167 @@
+package transcript
+
+import (
+	"chatgpt/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	FormatMarkdown = "md"
+	FormatHTML     = "html"
+	FormatJSON     = "json"
+	FormatText     = "txt"
+
+	LocaleRu      = "ru"
+	LocaleEn      = "en"
+	LocaleDefault = LocaleRu
+)
+
+var ErrUnknownFormat = errors.New("unknown transcript format")
+
+var ContentTypes = map[string]string{
+	FormatMarkdown: "text/markdown; charset=utf-8",
+	FormatHTML:     "text/html; charset=utf-8",
+	FormatJSON:     "application/json; charset=utf-8",
+	FormatText:     "text/plain; charset=utf-8",
+}
+
+// Labels are the localized headers of a transcript.
+type Labels struct {
+	Title     string `json:"title"`
+	Exported  string `json:"exported"`
+	User      string `json:"user"`
+	Assistant string `json:"assistant"`
+	Redacted  string `json:"redacted"`
+}
+
+var labels = map[string]Labels{
+	LocaleRu: {
+		Title:     "Переписка с TheraChat",
+		Exported:  "Экспортировано",
+		User:      "Вы",
+		Assistant: "TheraChat",
+		Redacted:  "[скрыто]",
+	},
+	LocaleEn: {
+		Title:     "TheraChat conversation",
+		Exported:  "Exported",
+		User:      "You",
+		Assistant: "TheraChat",
+		Redacted:  "[redacted]",
+	},
+}
+
+type Transcript struct {
+	Conversation string           `json:"conversation"`
+	Locale       string           `json:"locale"`
+	ExportedAt   time.Time        `json:"exportedAt"`
+	Messages     []models.Message `json:"messages"`
+}
+
+type Options struct {
+	Locale string
+	// Redact hides emails, phone numbers and the given personal details.
+	Redact   bool
+	Personal []string
+	Location *time.Location
+}
+
+// Render writes the conversation in the given format.
+func Render(w io.Writer, format string, conversation string, messages []models.Message, opts Options) error {
+	if _, ok := labels[opts.Locale]; !ok {
+		opts.Locale = LocaleDefault
+	}
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+
+	t := Transcript{
+		Conversation: conversation,
+		Locale:       opts.Locale,
+		ExportedAt:   time.Now().In(opts.Location),
+		Messages:     make([]models.Message, 0, len(messages)),
+	}
+
+	for _, m := range messages {
+		if opts.Redact {
+			m.Text = redact(m.Text, opts.Personal, labels[opts.Locale].Redacted)
+		}
+		t.Messages = append(t.Messages, m)
+	}
+
+	switch format {
+	case FormatMarkdown:
+		return renderText(w, t, opts, true)
+	case FormatText:
+		return renderText(w, t, opts, false)
+	case FormatHTML:
+		return renderHTML(w, t, opts)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(t)
+	}
+
+	return ErrUnknownFormat
+}
+
+func renderText(w io.Writer, t Transcript, opts Options, markdown bool) error {
+	l := labels[t.Locale]
+
+	var b strings.Builder
+	if markdown {
+		fmt.Fprintf(&b, "# %s\n\n_%s: %s_\n\n", l.Title, l.Exported, formatTime(t.ExportedAt, opts.Location))
+	} else {
+		fmt.Fprintf(&b, "%s\n%s: %s\n\n", l.Title, l.Exported, formatTime(t.ExportedAt, opts.Location))
+	}
+
+	for _, m := range t.Messages {
+		when := formatTime(time.Unix(m.CreatedAt, 0), opts.Location)
+		if markdown {
+			fmt.Fprintf(&b, "**%s** · %s\n\n%s\n\n---\n\n", role(l, m.Role), when, m.Text)
+		} else {
+			fmt.Fprintf(&b, "[%s] %s:\n%s\n\n", when, role(l, m.Role), m.Text)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func role(l Labels, role string) string {
+	if role == "user" {
+		return l.User
+	}
+	return l.Assistant
+}
+
+func formatTime(t time.Time, location *time.Location) string {
+	return t.In(location).Format("2006-01-02 15:04")
+}
+
+var (
+	emailPattern = regexp.MustCompile(`[\w.+-]+@[\w-]+\.[\w.-]+`)
+	phonePattern = regexp.MustCompile(`\+?\d[\d\s()-]{8,}\d`)
+)
+
+func redact(text string, personal []string, placeholder string) string {
+	text = emailPattern.ReplaceAllLiteralString(text, placeholder)
+	text = phonePattern.ReplaceAllLiteralString(text, placeholder)
+
+	for _, p := range personal {
+		if len([]rune(p)) < 2 {
+			continue
+		}
+		// \b is ASCII only, so word boundaries are matched by hand for cyrillic names.
+		re := regexp.MustCompile(`(?i)(^|[^\p{L}\d])` + regexp.QuoteMeta(p) + `($|[^\p{L}\d])`)
+		text = re.ReplaceAllString(text, "${1}"+strings.ReplaceAll(placeholder, "$", "$$")+"${2}")
+	}
+
+	return text
+}